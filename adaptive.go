@@ -0,0 +1,125 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// adaptiveInterval is how often -adaptive-mix re-balances the selection
+	// probabilities.
+	adaptiveInterval = 100 * time.Millisecond
+	// adaptiveMinOps is the number of completed SET/GET/DEL operations
+	// needed before the achieved mix is trusted enough to correct.
+	adaptiveMinOps = 100
+	// adaptiveMaxStep bounds how much one correction may scale a
+	// probability, so a noisy interval cannot swing the mix.
+	adaptiveMaxStep = 1.25
+)
+
+// adaptiveMix steers the SET/GET/DEL selection probabilities so that the
+// completed operation counts converge to the configured ratios. Under a
+// closed loop, operations that fail or are skipped (for example while
+// -oom-pause is active) leave the completed mix short of what was selected;
+// the controller compensates by selecting those operations more often. The
+// other operation types keep their configured share.
+type adaptiveMix struct {
+	target  opRatios
+	current atomic.Pointer[opRatios]
+}
+
+func newAdaptiveMix(target opRatios) *adaptiveMix {
+	m := &adaptiveMix{target: target}
+	r := target
+	m.current.Store(&r)
+	return m
+}
+
+// ratios returns the selection probabilities workers should use now.
+func (m *adaptiveMix) ratios() opRatios {
+	return *m.current.Load()
+}
+
+// run re-balances the probabilities from the run's completed counts until
+// stop is closed.
+func (m *adaptiveMix) run(r *benchmarkRun, stop <-chan struct{}) {
+	ticker := time.NewTicker(adaptiveInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			m.adjust(setGetDel(r.counters))
+		}
+	}
+}
+
+// adjust scales each probability by how far its operation's completed share
+// trails or leads the target, then rescales SET/GET/DEL so together they
+// keep their configured share of the mix.
+func (m *adaptiveMix) adjust(set, get, del int) {
+	total := set + get + del
+	if total < adaptiveMinOps {
+		return
+	}
+	t := m.target
+	budget := t.set + t.get + t.del
+	if budget == 0 {
+		return
+	}
+
+	cur := m.ratios()
+	step := func(p, target float64, completed int) float64 {
+		if target == 0 {
+			return 0
+		}
+		want := target / budget
+		got := float64(completed) / float64(total)
+		if got == 0 {
+			return p * adaptiveMaxStep
+		}
+		return p * math.Max(1/adaptiveMaxStep, math.Min(adaptiveMaxStep, want/got))
+	}
+	next := cur
+	next.set = step(cur.set, t.set, set)
+	next.get = step(cur.get, t.get, get)
+	next.del = step(cur.del, t.del, del)
+
+	sum := next.set + next.get + next.del
+	if sum == 0 {
+		return
+	}
+	next.set *= budget / sum
+	next.get *= budget / sum
+	next.del *= budget / sum
+	m.current.Store(&next)
+}
+
+// printAdaptiveMix compares the completed SET/GET/DEL mix to the target and
+// shows the selection probabilities the controller ended up with.
+func (r *benchmarkRun) printAdaptiveMix() {
+	t := r.mix.target
+	budget := t.set + t.get + t.del
+	total := r.totalSet + r.totalGet + r.totalDel
+	if budget == 0 || total == 0 {
+		return
+	}
+	final := r.mix.ratios()
+	fmt.Println("Adaptive operation mix (share of completed SET/GET/DEL):")
+	for _, op := range []struct {
+		name             string
+		target, selected float64
+		completed        int
+	}{
+		{"SET", t.set, final.set, r.totalSet},
+		{"GET", t.get, final.get, r.totalGet},
+		{"DEL", t.del, final.del, r.totalDel},
+	} {
+		fmt.Printf("  %s: target=%.2f%%, achieved=%.2f%%, final selection probability=%.2f%%\n",
+			op.name, op.target/budget*100, float64(op.completed)/float64(total)*100, op.selected/budget*100)
+	}
+}
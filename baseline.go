@@ -0,0 +1,88 @@
+package main
+
+import (
+	"fmt"
+)
+
+// exitRegression is the exit status when a run regressed against the
+// -compare baseline by more than -regression-threshold.
+const exitRegression = 5
+
+// regressionDetected is set when the run regressed against the baseline.
+var regressionDetected bool
+
+// compareToBaseline prints the change of every operation the run shares
+// with the baseline report: ops/sec, p50 and p99 latency. Changes beyond
+// -regression-threshold percent are marked, with ! for a regression (less
+// throughput or more latency) and * for an improvement. It reports whether
+// any metric regressed.
+func compareToBaseline(path string, baseline, current Report) bool {
+	fmt.Printf("\nComparison with baseline %s (threshold %.1f%%):\n", path, regressionThreshold)
+	fmt.Printf("%-10s %14s %14s %10s %10s %10s\n",
+		"Operation", "Base ops/sec", "Ops/sec", "Ops delta", "P50 delta", "P99 delta")
+
+	base := map[string]OperationReport{}
+	for _, op := range baseline.Operations {
+		base[op.Name] = op
+	}
+	regressed := false
+	for _, op := range current.Operations {
+		b, ok := base[op.Name]
+		if !ok || b.Count == 0 || op.Count == 0 {
+			continue
+		}
+		cells := make([]string, 0, 3)
+		for _, m := range []struct {
+			base, current  float64
+			higherIsBetter bool
+		}{
+			{b.OpsPerSec, op.OpsPerSec, true},
+			{b.P50Ms, op.P50Ms, false},
+			{b.P99Ms, op.P99Ms, false},
+		} {
+			cell, worse := markChange(m.base, m.current, m.higherIsBetter)
+			regressed = regressed || worse
+			cells = append(cells, cell)
+		}
+		fmt.Printf("%-10s %14.2f %14.2f %10s %10s %10s\n",
+			op.Name, b.OpsPerSec, op.OpsPerSec, cells[0], cells[1], cells[2])
+	}
+	fmt.Println("! regression, * improvement beyond the threshold")
+	if regressed {
+		fmt.Printf("Regression beyond -regression-threshold %.1f%% against %s\n", regressionThreshold, path)
+	}
+	return regressed
+}
+
+// markChange formats the change from base to current and marks it when it
+// exceeds -regression-threshold. worse reports a marked regression.
+func markChange(base, current float64, higherIsBetter bool) (cell string, worse bool) {
+	// A latency of 0 was not reported, as by merge without histograms
+	if current == 0 && !higherIsBetter {
+		return "n/a", false
+	}
+	cell = percentChange(base, current)
+	if base == 0 {
+		return cell, false
+	}
+	change := (current - base) / base * 100
+	if !higherIsBetter {
+		change = -change
+	}
+	switch {
+	case change < -regressionThreshold:
+		return cell + "!", true
+	case change > regressionThreshold:
+		return cell + "*", false
+	}
+	return cell + " ", false
+}
+
+// exitOnRegression exits with exitRegression if the run regressed against
+// the -compare baseline. Like exitOnErrorRate main calls it once run has
+// returned, so the run's deferred cleanup has already happened.
+func exitOnRegression() {
+	if regressionDetected {
+		exit(exitRegression)
+	}
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"math/rand"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// msetBatch writes n keys, -batch unless the -requests budget runs
+// short, with one MSET, starting with key, and the rest drawn from picker. MSET cannot set a TTL, so the keys are
+// persistent. The latency is that of the whole call; the SET counter grows
+// by the number of keys.
+func msetBatch(ctx context.Context, rng *rand.Rand, workerID int, client benchClient, keys []string, key string, picker *keyPicker, run *benchmarkRun, n int) {
+	stats := run.workerStats[workerID-1]
+	counters := &run.counters[workerID-1]
+
+	pairs := make([]interface{}, 0, 2*n)
+	var bytes int64
+	for i := 0; i < n; i++ {
+		if i > 0 {
+			key = keys[picker.next()]
+		}
+		var value string
+		if run.intValues {
+			value = strconv.FormatInt(rng.Int63n(1e9), 10)
+		} else {
+			value = generateValue(rng, workerID, run.setValueSize(rng))
+		}
+		if checksums != nil {
+			checksums.writing(key, []byte(value))
+		}
+		pairs = append(pairs, key, value)
+		bytes += int64(len(key) + len(value))
+	}
+
+	start := time.Now()
+	err := client.MSet(ctx, pairs...).Err()
+	if oomPause > 0 {
+		run.oom.record(err)
+	}
+	if err != nil {
+		recordError(&stats.set)
+		return
+	}
+	updateStats(&stats.set, time.Since(start).Seconds()*1000)
+	atomic.AddInt64(&counters.set, int64(n))
+	atomic.AddInt64(&counters.setBytes, bytes)
+}
+
+// mgetBatch reads n keys, -batch unless the -requests budget runs
+// short, with one MGET, starting with key, and the rest drawn from picker. The latency is that of the whole call; the GET
+// counter grows by the number of keys and the hit counter by those found.
+func mgetBatch(ctx context.Context, workerID int, client benchClient, keys []string, key string, picker *keyPicker, run *benchmarkRun, n int) {
+	stats := run.workerStats[workerID-1]
+	counters := &run.counters[workerID-1]
+
+	batch := make([]string, n)
+	batch[0] = key
+	for i := 1; i < n; i++ {
+		batch[i] = keys[picker.next()]
+	}
+
+	start := time.Now()
+	values, err := client.MGet(ctx, batch...).Result()
+	if err != nil {
+		recordError(&stats.get)
+		return
+	}
+	updateStats(&stats.get, time.Since(start).Seconds()*1000)
+
+	var hits, bytes int64
+	for i, v := range values {
+		bytes += int64(len(batch[i]))
+		value, ok := v.(string)
+		if !ok {
+			continue
+		}
+		hits++
+		bytes += int64(len(value))
+		if checksums != nil {
+			checksums.read(batch[i], value)
+		}
+	}
+	atomic.AddInt64(&counters.get, int64(n))
+	atomic.AddInt64(&counters.getHits, hits)
+	atomic.AddInt64(&counters.getBytes, bytes)
+}
@@ -0,0 +1,219 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// bgsavePollInterval is how often INFO persistence is polled while waiting
+// for the save started by -bgsave-at to begin and finish.
+const bgsavePollInterval = 10 * time.Millisecond
+
+type bgsaveStartKey struct{}
+
+// bgsaveProbe is a go-redis hook that splits command latency into the
+// window of a BGSAVE triggered with -bgsave-at and the steady state around
+// it, so the latency cost of the fork and the save can be quantified.
+type bgsaveProbe struct {
+	admin *redis.Client
+
+	mu                   sync.Mutex
+	saving               bool
+	steady, window       latencyHistogram
+	steadyMax, windowMax float64
+}
+
+// bgsaveResult describes the save of one run.
+type bgsaveResult struct {
+	at       time.Duration // Offset of the BGSAVE into the run
+	elapsed  time.Duration // From BGSAVE until INFO reported the save done
+	forkUsec int64         // latest_fork_usec after the save
+	status   string        // rdb_last_bgsave_status after the save
+	finished bool          // False if the run ended before the save did
+	err      error
+
+	steady, window       latencyHistogram
+	steadyMax, windowMax float64
+}
+
+func newBGSaveProbe(admin *redis.Client) *bgsaveProbe {
+	return &bgsaveProbe{admin: admin}
+}
+
+func (p *bgsaveProbe) record(start time.Time, err error) {
+	if err != nil && err != redis.Nil {
+		return
+	}
+	ms := time.Since(start).Seconds() * 1000
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.saving {
+		p.window.record(ms)
+		if ms > p.windowMax {
+			p.windowMax = ms
+		}
+		return
+	}
+	p.steady.record(ms)
+	if ms > p.steadyMax {
+		p.steadyMax = ms
+	}
+}
+
+func (p *bgsaveProbe) BeforeProcess(ctx context.Context, cmd redis.Cmder) (context.Context, error) {
+	return context.WithValue(ctx, bgsaveStartKey{}, time.Now()), nil
+}
+
+func (p *bgsaveProbe) AfterProcess(ctx context.Context, cmd redis.Cmder) error {
+	if start, ok := ctx.Value(bgsaveStartKey{}).(time.Time); ok {
+		p.record(start, cmd.Err())
+	}
+	return nil
+}
+
+func (p *bgsaveProbe) BeforeProcessPipeline(ctx context.Context, cmds []redis.Cmder) (context.Context, error) {
+	return context.WithValue(ctx, bgsaveStartKey{}, time.Now()), nil
+}
+
+func (p *bgsaveProbe) AfterProcessPipeline(ctx context.Context, cmds []redis.Cmder) error {
+	if start, ok := ctx.Value(bgsaveStartKey{}).(time.Time); ok {
+		for _, cmd := range cmds {
+			p.record(start, cmd.Err())
+		}
+	}
+	return nil
+}
+
+func (p *bgsaveProbe) setSaving(saving bool) {
+	p.mu.Lock()
+	p.saving = saving
+	p.mu.Unlock()
+}
+
+// schedule resets the collected latencies for a new run and triggers BGSAVE
+// after at. The result is sent once the save has finished, or when ctx ends
+// first; it does not include the latencies, which are taken by collect after
+// the run has stopped.
+func (p *bgsaveProbe) schedule(ctx context.Context, at time.Duration) <-chan bgsaveResult {
+	p.mu.Lock()
+	p.saving = false
+	p.steady.reset()
+	p.window.reset()
+	p.steadyMax, p.windowMax = 0, 0
+	p.mu.Unlock()
+
+	done := make(chan bgsaveResult, 1)
+	go func() {
+		res := bgsaveResult{at: at}
+		defer func() { done <- res }()
+
+		select {
+		case <-ctx.Done():
+			res.err = fmt.Errorf("run ended before BGSAVE was due")
+			return
+		case <-time.After(at):
+		}
+
+		start := time.Now()
+		p.setSaving(true)
+		defer p.setSaving(false)
+		if err := p.admin.BgSave(ctx).Err(); err != nil {
+			res.err = err
+			return
+		}
+
+		// BGSAVE may only be scheduled behind an AOF rewrite, so wait for the
+		// save to show up in INFO before waiting for it to finish.
+		started := false
+		ticker := time.NewTicker(bgsavePollInterval)
+		defer ticker.Stop()
+		for {
+			fields, err := persistenceInfo(ctx, p.admin)
+			if err != nil {
+				res.elapsed = time.Since(start)
+				if ctx.Err() == nil {
+					res.err = err
+				}
+				return
+			}
+			inProgress := fields["rdb_bgsave_in_progress"] == "1"
+			if inProgress {
+				started = true
+			}
+			// A save that forks and finishes between two polls is never seen
+			// in progress; a new rdb_last_save_time shows it has completed.
+			if started && !inProgress || !started && savedSince(fields, start) {
+				res.elapsed = time.Since(start)
+				res.finished = true
+				res.forkUsec, _ = strconv.ParseInt(fields["latest_fork_usec"], 10, 64)
+				res.status = fields["rdb_last_bgsave_status"]
+				return
+			}
+			select {
+			case <-ctx.Done():
+				res.elapsed = time.Since(start)
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	return done
+}
+
+// collect adds the latencies recorded during the run to res.
+func (p *bgsaveProbe) collect(res *bgsaveResult) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	res.steady, res.window = p.steady, p.window
+	res.steadyMax, res.windowMax = p.steadyMax, p.windowMax
+}
+
+func persistenceInfo(ctx context.Context, rdb *redis.Client) (map[string]string, error) {
+	info, err := rdb.Info(ctx, "persistence").Result()
+	if err != nil {
+		return nil, err
+	}
+	return parseInfo(info), nil
+}
+
+// savedSince reports whether INFO persistence shows a save that completed
+// at or after start.
+func savedSince(fields map[string]string, start time.Time) bool {
+	last, err := strconv.ParseInt(fields["rdb_last_save_time"], 10, 64)
+	return err == nil && last >= start.Unix()
+}
+
+// printBGSave compares the latency during the save window with the steady
+// state before and after it.
+func printBGSave(res *bgsaveResult) {
+	if res.err != nil {
+		fmt.Printf("BGSAVE at %v: failed: %v\n", res.at, res.err)
+		return
+	}
+	if res.finished {
+		fmt.Printf("BGSAVE at %v: fork took %.2f ms, save finished after %v (status %s)\n",
+			res.at, float64(res.forkUsec)/1000, res.elapsed.Round(time.Millisecond), res.status)
+	} else {
+		fmt.Printf("BGSAVE at %v: still running when the run ended after %v; fork time not available\n",
+			res.at, res.elapsed.Round(time.Millisecond))
+	}
+	printWindow := func(name string, h *latencyHistogram, max float64) {
+		if h.total == 0 {
+			fmt.Printf("  %s latency: no operations\n", name)
+			return
+		}
+		fmt.Printf("  %s latency (ms): P50=%.2f, P99=%.2f, Max=%.2f (%d operations)\n",
+			name, h.percentile(50), h.percentile(99), max, h.total)
+	}
+	printWindow("Steady state", &res.steady, res.steadyMax)
+	printWindow("During save", &res.window, res.windowMax)
+	if res.steady.total > 0 && res.window.total > 0 {
+		fmt.Printf("  Worst latency during save: %.1fx steady-state P99, %.1fx steady-state max\n",
+			res.windowMax/res.steady.percentile(99), res.windowMax/res.steadyMax)
+	}
+}
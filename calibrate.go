@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sort"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// latencyFloor is the unloaded latency of one operation type, measured by
+// calibrateLatencyFloor before the run.
+type latencyFloor struct {
+	name     string
+	min, p50 float64
+}
+
+// calibrateLatencyFloor issues n sequential SET, GET and DEL commands from a
+// single connection before any load is applied. Their latency is the best
+// the client and server can do, which puts the loaded numbers in context.
+func calibrateLatencyFloor(ctx context.Context, client benchClient, n int) ([]latencyFloor, error) {
+	key := keyPrefix + "calibrate"
+	value := generateValue(rand.New(rand.NewSource(seed)), 0, valueSize.get("set"))
+	ops := []struct {
+		name string
+		do   func() error
+	}{
+		{"SET", func() error { return client.Set(ctx, key, value, ttl).Err() }},
+		{"GET", func() error {
+			if err := client.Get(ctx, key).Err(); err != redis.Nil {
+				return err
+			}
+			return nil
+		}},
+		{"DEL", func() error { return client.Del(ctx, key).Err() }},
+	}
+
+	floors := make([]latencyFloor, 0, len(ops))
+	for _, op := range ops {
+		samples := make([]float64, 0, n)
+		for i := 0; i < n; i++ {
+			start := time.Now()
+			if err := op.do(); err != nil {
+				return nil, fmt.Errorf("%s: %w", op.name, err)
+			}
+			samples = append(samples, time.Since(start).Seconds()*1000)
+		}
+		sort.Float64s(samples)
+		floors = append(floors, latencyFloor{name: op.name, min: samples[0], p50: percentile(samples, 50)})
+	}
+	return floors, nil
+}
+
+// printLatencyFloor compares the loaded average latency of each operation
+// with its unloaded floor.
+func printLatencyFloor(floors []latencyFloor, stats map[string]*operationStats) {
+	for _, f := range floors {
+		s := stats[f.name]
+		if s.count == 0 || f.p50 == 0 {
+			fmt.Printf("%s latency floor (ms): Min=%.3f, P50=%.3f\n", f.name, f.min, f.p50)
+			continue
+		}
+		avg := s.totalTime / float64(s.count)
+		fmt.Printf("%s latency floor (ms): Min=%.3f, P50=%.3f; loaded Avg=%.3f (%.1fx floor)\n",
+			f.name, f.min, f.p50, avg, avg/f.p50)
+	}
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/rand"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// churnClientName is set on every benchmark connection with -kill-interval
+// so the killer can find them in CLIENT LIST.
+const churnClientName = "another-redis-benchmark"
+
+// churnMonitor kills benchmark connections with CLIENT KILL at a fixed
+// interval and measures how the pool recovers: how many connections are
+// dialed again, how long that takes and how many commands fail meanwhile.
+// It is installed as both the dialer wrapper and a hook of every client.
+type churnMonitor struct {
+	mu        sync.Mutex
+	active    bool
+	kills     int
+	killFails int
+	killErr   error
+	errors    int
+	dials     operationStats
+}
+
+func newChurnMonitor() *churnMonitor {
+	return &churnMonitor{dials: operationStats{minTime: math.MaxFloat64}}
+}
+
+// dialer wraps base, or a plain net.Dialer when base is nil, to time the
+// connections dialed while the killer is running.
+func (c *churnMonitor) dialer(base func(ctx context.Context, network, addr string) (net.Conn, error)) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	if base == nil {
+		var d net.Dialer
+		base = d.DialContext
+	}
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		start := time.Now()
+		conn, err := base(ctx, network, addr)
+		if err == nil {
+			c.mu.Lock()
+			if c.active {
+				updateStats(&c.dials, time.Since(start).Seconds()*1000)
+			}
+			c.mu.Unlock()
+		}
+		return conn, err
+	}
+}
+
+// onConnect names the connection so killLoop can tell it apart from other
+// clients of the server.
+func (c *churnMonitor) onConnect(ctx context.Context, cn *redis.Conn) error {
+	return cn.ClientSetName(ctx, churnClientName).Err()
+}
+
+func (c *churnMonitor) recordErr(err error) {
+	if err == nil || err == redis.Nil {
+		return
+	}
+	c.mu.Lock()
+	if c.active {
+		c.errors++
+	}
+	c.mu.Unlock()
+}
+
+func (c *churnMonitor) BeforeProcess(ctx context.Context, cmd redis.Cmder) (context.Context, error) {
+	return ctx, nil
+}
+
+func (c *churnMonitor) AfterProcess(ctx context.Context, cmd redis.Cmder) error {
+	c.recordErr(cmd.Err())
+	return nil
+}
+
+func (c *churnMonitor) BeforeProcessPipeline(ctx context.Context, cmds []redis.Cmder) (context.Context, error) {
+	return ctx, nil
+}
+
+func (c *churnMonitor) AfterProcessPipeline(ctx context.Context, cmds []redis.Cmder) error {
+	for _, cmd := range cmds {
+		c.recordErr(cmd.Err())
+	}
+	return nil
+}
+
+// killLoop kills one random benchmark connection every interval until stop
+// is closed. admin must not carry churnClientName, so it never kills itself.
+func (c *churnMonitor) killLoop(ctx context.Context, admin *redis.Client, interval time.Duration, stop <-chan struct{}) {
+	c.mu.Lock()
+	c.active = true
+	c.mu.Unlock()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			err := killRandomConnection(ctx, admin)
+			c.mu.Lock()
+			if err != nil {
+				c.killFails++
+				c.killErr = err
+			} else {
+				c.kills++
+			}
+			c.mu.Unlock()
+		}
+	}
+}
+
+// killRandomConnection picks a connection named churnClientName from CLIENT
+// LIST and kills it by ID.
+func killRandomConnection(ctx context.Context, admin *redis.Client) error {
+	list, err := admin.ClientList(ctx).Result()
+	if err != nil {
+		return err
+	}
+	var ids []string
+	for _, line := range strings.Split(list, "\n") {
+		var id, name string
+		for _, field := range strings.Fields(line) {
+			k, v, _ := strings.Cut(field, "=")
+			switch k {
+			case "id":
+				id = v
+			case "name":
+				name = v
+			}
+		}
+		if name == churnClientName && id != "" {
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) == 0 {
+		return fmt.Errorf("no benchmark connections to kill")
+	}
+	return admin.Do(ctx, "CLIENT", "KILL", "ID", ids[rand.Intn(len(ids))]).Err()
+}
+
+// print reports the kills and the recovery they caused. completed is the
+// number of operations that succeeded during the run.
+func (c *churnMonitor) print(completed int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	errRate := 0.0
+	if total := completed + c.errors; total > 0 {
+		errRate = float64(c.errors) / float64(total) * 100
+	}
+	fmt.Printf("Connection churn (CLIENT KILL every %v): kills=%d, failed kills=%d, connections dialed=%d (including the initial pool)\n",
+		killInterval, c.kills, c.killFails, c.dials.count)
+	if c.killErr != nil {
+		fmt.Printf("Last CLIENT KILL error: %v\n", c.killErr)
+	}
+	fmt.Printf("Failed commands during churn: %d (%.2f%% of commands)\n", c.errors, errRate)
+	printStats("Connection dial", &c.dials)
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// cleanupBatch is how many keys each cleanup worker removes per pipeline.
+const cleanupBatch = 500
+
+// cleanupKeys removes the benchmark keys after the run, with UNLINK when
+// the server has it (Redis 4.0 and later) so the memory is reclaimed in
+// the background, and DEL otherwise. Like preloadKeys it splits the keys
+// among numClients workers sending pipelined batches, and with -dbs it
+// cleans every database in turn. It uses its own clients, so the deletes
+// do not show up in the hooks that record the run. It returns the number
+// of keys that existed and were removed.
+func cleanupKeys(ctx context.Context, opts *redis.Options, keys []string) (int64, error) {
+	dbs := databases
+	if dbs == nil {
+		dbs = []int{opts.DB}
+	}
+	workers := min(numClients, len(keys))
+	if workers == 0 {
+		return 0, nil
+	}
+	per := (len(keys) + workers - 1) / workers
+
+	var removed int64
+	for _, db := range dbs {
+		o := *opts
+		o.OnConnect = nil
+		o.PoolSize = workers
+		o.MinIdleConns = 0
+		o.DB = db
+		client := newBenchClient(&o)
+
+		version, _ := serverVersion(ctx, client)
+		unlink := versionAtLeast(version, 4, 0)
+
+		var wg sync.WaitGroup
+		errs := make([]error, workers)
+		for w := 0; w < workers; w++ {
+			first, last := w*per, min((w+1)*per, len(keys))
+			wg.Add(1)
+			go func(w, first, last int) {
+				defer wg.Done()
+				n, err := cleanupRange(ctx, client, keys[first:last], unlink)
+				atomic.AddInt64(&removed, n)
+				errs[w] = err
+			}(w, first, last)
+		}
+		wg.Wait()
+		client.Close()
+		for _, err := range errs {
+			if err != nil {
+				return removed, fmt.Errorf("database %d: %w", db, err)
+			}
+		}
+	}
+	return removed, nil
+}
+
+// cleanupRange removes keys in pipelined batches of single-key commands,
+// which a cluster client can route to the node owning each key.
+func cleanupRange(ctx context.Context, client benchClient, keys []string, unlink bool) (int64, error) {
+	var removed int64
+	for i := 0; i < len(keys); i += cleanupBatch {
+		batch := keys[i:min(i+cleanupBatch, len(keys))]
+		pipe := client.Pipeline()
+		cmds := make([]*redis.IntCmd, len(batch))
+		for j, key := range batch {
+			if unlink {
+				cmds[j] = pipe.Unlink(ctx, key)
+			} else {
+				cmds[j] = pipe.Del(ctx, key)
+			}
+		}
+		if _, err := pipe.Exec(ctx); err != nil {
+			return removed, fmt.Errorf("removing %s: %w", batch[0], err)
+		}
+		for _, cmd := range cmds {
+			removed += cmd.Val()
+		}
+	}
+	return removed, nil
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// benchClient is the part of the go-redis API the benchmark uses. Both
+// *redis.Client and *redis.ClusterClient implement it, so the workers run
+// unchanged against a single server, a master found through Sentinel or a
+// cluster that answers with MOVED/ASK redirections.
+type benchClient interface {
+	redis.Cmdable
+	Do(ctx context.Context, args ...interface{}) *redis.Cmd
+	Subscribe(ctx context.Context, channels ...string) *redis.PubSub
+	AddHook(hook redis.Hook)
+	PoolStats() *redis.PoolStats
+	Close() error
+}
+
+// newBenchClient connects to -addr with opts, or with -cluster treats -addr
+// as a comma-separated list of seed nodes and returns a cluster client that
+// shares the dialer, credentials and hooks configured in opts.
+func newBenchClient(opts *redis.Options) benchClient {
+	if !clusterMode {
+		return newServerClient(opts)
+	}
+	return redis.NewClusterClient(&redis.ClusterOptions{
+		Addrs:        strings.Split(opts.Addr, ","),
+		Dialer:       opts.Dialer,
+		OnConnect:    opts.OnConnect,
+		Username:     opts.Username,
+		Password:     opts.Password,
+		PoolSize:     opts.PoolSize,
+		MinIdleConns: opts.MinIdleConns,
+		PoolTimeout:  opts.PoolTimeout,
+		TLSConfig:    opts.TLSConfig,
+
+		MaxRetries:      opts.MaxRetries,
+		MinRetryBackoff: opts.MinRetryBackoff,
+		MaxRetryBackoff: opts.MaxRetryBackoff,
+	})
+}
+
+// newServerClient connects to -addr with opts, or with -sentinel-master
+// treats -addr as a comma-separated list of sentinels and returns a client
+// that follows the named master across failovers.
+func newServerClient(opts *redis.Options) *redis.Client {
+	if sentinelMaster == "" {
+		return redis.NewClient(opts)
+	}
+	return redis.NewFailoverClient(&redis.FailoverOptions{
+		MasterName:    sentinelMaster,
+		SentinelAddrs: strings.Split(opts.Addr, ","),
+		Dialer:        opts.Dialer,
+		OnConnect:     opts.OnConnect,
+		Username:      opts.Username,
+		Password:      opts.Password,
+		DB:            opts.DB,
+		PoolSize:      opts.PoolSize,
+		MinIdleConns:  opts.MinIdleConns,
+		PoolTimeout:   opts.PoolTimeout,
+		TLSConfig:     opts.TLSConfig,
+
+		MaxRetries:      opts.MaxRetries,
+		MinRetryBackoff: opts.MinRetryBackoff,
+		MaxRetryBackoff: opts.MaxRetryBackoff,
+	})
+}
+
+// nodeClients returns the client itself, or a client for each master of a
+// cluster, for checks that have to reach every server.
+func nodeClients(ctx context.Context, client benchClient) ([]*redis.Client, error) {
+	cc, ok := client.(*redis.ClusterClient)
+	if !ok {
+		return []*redis.Client{client.(*redis.Client)}, nil
+	}
+
+	var mu sync.Mutex
+	var nodes []*redis.Client
+	err := cc.ForEachMaster(ctx, func(ctx context.Context, c *redis.Client) error {
+		mu.Lock()
+		defer mu.Unlock()
+		nodes = append(nodes, c)
+		return nil
+	})
+	return nodes, err
+}
+
+// pingNodes verifies the connection to the server, or to every master of a
+// cluster, and returns the number of nodes that answered.
+func pingNodes(ctx context.Context, client benchClient) (int, error) {
+	nodes, err := nodeClients(ctx, client)
+	if err != nil {
+		return 0, err
+	}
+	for _, node := range nodes {
+		if err := node.Ping(ctx).Err(); err != nil {
+			return 0, err
+		}
+	}
+	return len(nodes), nil
+}
+
+// logPoolConfig logs the connection pool settings the client ended up
+// with, after go-redis filled in its defaults. A cluster client has one
+// pool per node.
+func logPoolConfig(client benchClient) {
+	switch c := client.(type) {
+	case *redis.Client:
+		o := c.Options()
+		logger.Info("Connection pool", "size", o.PoolSize, "min_idle", o.MinIdleConns, "timeout", o.PoolTimeout, "max_retries", o.MaxRetries)
+	case *redis.ClusterClient:
+		o := c.Options()
+		logger.Info("Connection pool (per node)", "size", o.PoolSize, "min_idle", o.MinIdleConns, "timeout", o.PoolTimeout, "max_retries", o.MaxRetries)
+	}
+}
@@ -0,0 +1,270 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// subcommands are the commands besides run, which is the default when the
+// first argument is not a known command name.
+var subcommands = map[string]func(args []string){
+	"compare": compareCommand,
+	"merge":   mergeCommand,
+	"info":    infoCommand,
+}
+
+func usage() {
+	out := flag.CommandLine.Output()
+	fmt.Fprintf(out, "Usage:\n")
+	for _, c := range [][2]string{
+		{"[run] [flags]", "run the benchmark (default)"},
+		{"compare baseline.json candidate.json", "compare two JSON results"},
+		{"merge [-o file] result.json...", "combine JSON results of parallel runs"},
+		{"info [flags]", "print a server preflight report"},
+	} {
+		fmt.Fprintf(out, "  %s %-38s %s\n", os.Args[0], c[0], c[1])
+	}
+	fmt.Fprintf(out, "\nFlags of run:\n")
+	flag.PrintDefaults()
+}
+
+// readReport loads a JSON report as sent to -results-callback-url.
+func readReport(path string) (Report, error) {
+	var r Report
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return r, err
+	}
+	if err := json.Unmarshal(data, &r); err != nil {
+		return r, fmt.Errorf("%s: %w", path, err)
+	}
+	r.Duration = time.Duration(r.DurationSeconds * float64(time.Second))
+	return r, nil
+}
+
+func compareCommand(args []string) {
+	fs := flag.NewFlagSet("compare", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s compare baseline.json candidate.json\n", os.Args[0])
+	}
+	fs.Parse(args)
+	if fs.NArg() != 2 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	baseline, err := readReport(fs.Arg(0))
+	if err != nil {
+		log.Fatalf("Failed to read baseline: %v", err)
+	}
+	candidate, err := readReport(fs.Arg(1))
+	if err != nil {
+		log.Fatalf("Failed to read candidate: %v", err)
+	}
+	printReportComparison(fmt.Sprintf("Comparison (%s vs %s):", fs.Arg(1), fs.Arg(0)), baseline, candidate)
+}
+
+func mergeCommand(args []string) {
+	fs := flag.NewFlagSet("merge", flag.ExitOnError)
+	output := fs.String("o", "", "Write the merged JSON to this file instead of stdout")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s merge [-o file] result.json...\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	reports := make([]Report, fs.NArg())
+	for i, path := range fs.Args() {
+		r, err := readReport(path)
+		if err != nil {
+			log.Fatalf("Failed to read result: %v", err)
+		}
+		reports[i] = r
+	}
+
+	merged, err := mergeReports(reports)
+	if err != nil {
+		log.Fatalf("Failed to merge results: %v", err)
+	}
+	data, err := json.MarshalIndent(merged, "", "  ")
+	if err != nil {
+		log.Fatalf("Failed to encode merged result: %v", err)
+	}
+	data = append(data, '\n')
+	if *output == "" {
+		os.Stdout.Write(data)
+		return
+	}
+	if err := os.WriteFile(*output, data, 0o644); err != nil {
+		log.Fatalf("Failed to write merged result: %v", err)
+	}
+}
+
+// mergeReports combines the results of runs that executed at the same time,
+// for example from several load generator hosts. Counts, rates and bytes
+// add up, average latency is weighted by count, the error rate is
+// recomputed from the summed counts, and only tags shared by every run are
+// kept. Latency percentiles come from the merged histograms, and are left
+// out when a run did not record its histogram. Per-second throughput
+// percentiles cannot be combined and are left empty.
+func mergeReports(reports []Report) (Report, error) {
+	var merged Report
+	ops := map[string]*OperationReport{}
+	var order []string
+	weighted := map[string]float64{}
+	hists := map[string]*latencyHistogram{}
+	partial := map[string]bool{} // A run of the operation had no histogram
+	for i, r := range reports {
+		merged.Clients += r.Clients
+		if r.Keys > merged.Keys {
+			merged.Keys = r.Keys
+		}
+		if r.DurationSeconds > merged.DurationSeconds {
+			merged.DurationSeconds = r.DurationSeconds
+		}
+		merged.SetBytes += r.SetBytes
+		merged.GetBytes += r.GetBytes
+		merged.SetMBPerSec += r.SetMBPerSec
+		merged.GetMBPerSec += r.GetMBPerSec
+
+		for _, w := range r.Workers {
+			w.ID = len(merged.Workers) + 1
+			merged.Workers = append(merged.Workers, w)
+		}
+
+		for _, op := range r.Operations {
+			m, ok := ops[op.Name]
+			if !ok {
+				m = &OperationReport{Name: op.Name, MinMs: math.MaxFloat64}
+				ops[op.Name] = m
+				order = append(order, op.Name)
+			}
+			m.Count += op.Count
+			m.Errors += op.Errors
+			m.OpsPerSec += op.OpsPerSec
+			if op.Count > 0 {
+				m.MinMs = math.Min(m.MinMs, op.MinMs)
+				m.MaxMs = math.Max(m.MaxMs, op.MaxMs)
+				weighted[op.Name] += op.AvgMs * float64(op.Count)
+				if op.Histogram == "" {
+					partial[op.Name] = true
+					continue
+				}
+				h, err := decodeLatencyHistogram(op.Histogram)
+				if err != nil {
+					return Report{}, fmt.Errorf("%s histogram: %w", op.Name, err)
+				}
+				if hists[op.Name] == nil {
+					hists[op.Name] = &latencyHistogram{}
+				}
+				hists[op.Name].merge(&h)
+			}
+		}
+
+		if i == 0 {
+			merged.Tags = map[string]string{}
+			for k, v := range r.Tags {
+				merged.Tags[k] = v
+			}
+			continue
+		}
+		for k, v := range merged.Tags {
+			if r.Tags[k] != v {
+				delete(merged.Tags, k)
+			}
+		}
+	}
+
+	var count, errors int
+	for _, name := range order {
+		op := ops[name]
+		count += op.Count
+		errors += op.Errors
+		if op.Count > 0 {
+			op.AvgMs = weighted[name] / float64(op.Count)
+		} else {
+			op.MinMs = 0
+		}
+		if h := hists[name]; h != nil && !partial[name] {
+			op.P50Ms = h.percentile(50)
+			op.P95Ms = h.percentile(95)
+			op.P99Ms = h.percentile(99)
+			op.P999Ms = h.percentile(99.9)
+			var err error
+			if op.Histogram, err = h.encode(); err != nil {
+				return Report{}, fmt.Errorf("%s histogram: %w", name, err)
+			}
+		}
+		merged.Operations = append(merged.Operations, *op)
+	}
+	if count+errors > 0 {
+		merged.ErrorRate = float64(errors) / float64(count+errors)
+	}
+	merged.Duration = time.Duration(merged.DurationSeconds * float64(time.Second))
+	return merged, nil
+}
+
+func infoCommand(args []string) {
+	fs := flag.NewFlagSet("info", flag.ExitOnError)
+	fs.StringVar(&redisAddr, "addr", "localhost:6379", "Redis server address")
+	fs.StringVar(&redisUser, "user", "", "Redis ACL username (empty uses the default user)")
+	fs.StringVar(&redisPass, "pass", "", "Redis password (prefer -pass-file or REDIS_PASSWORD)")
+	fs.StringVar(&redisPassFile, "pass-file", "", "File containing the Redis password")
+	fs.IntVar(&redisDB, "db", 0, "Redis database number")
+	fs.Parse(args)
+
+	password, err := resolvePassword()
+	if err != nil {
+		log.Fatalf("Failed to read Redis password: %v", err)
+	}
+	rdb := redis.NewClient(&redis.Options{Addr: redisAddr, Username: redisUser, Password: password, DB: redisDB})
+	defer rdb.Close()
+
+	ctx := context.Background()
+	start := time.Now()
+	if err := rdb.Ping(ctx).Err(); err != nil {
+		log.Fatalf("Failed to connect to Redis: %v", err)
+	}
+	fmt.Printf("Server %s reachable (PING %v)\n", redisAddr, time.Since(start))
+
+	info, err := rdb.Info(ctx, "all").Result()
+	if err != nil {
+		fmt.Printf("INFO: unavailable (%v)\n", err)
+	} else {
+		fields := parseInfo(info)
+		for _, name := range []string{
+			"redis_version", "redis_mode", "os", "role", "connected_slaves",
+			"connected_clients", "used_memory_human", "maxmemory_human",
+			"maxmemory_policy", "aof_enabled", "rdb_bgsave_in_progress",
+		} {
+			value, ok := fields[name]
+			if !ok {
+				value = "n/a"
+			}
+			fmt.Printf("%-24s %s\n", name+":", value)
+		}
+	}
+
+	modules, err := loadedModules(ctx, rdb)
+	switch {
+	case err != nil:
+		fmt.Printf("%-24s unavailable (%v)\n", "modules:", err)
+	case len(modules) == 0:
+		fmt.Printf("%-24s none\n", "modules:")
+	default:
+		fmt.Printf("%-24s %s\n", "modules:", strings.Join(modules, " "))
+	}
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+	"time"
+)
+
+func TestMergeReportsPercentiles(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	var reference latencyHistogram
+	reports := make([]Report, 3)
+	for i := range reports {
+		stats := operationStats{minTime: math.MaxFloat64}
+		for j := 0; j < 10000; j++ {
+			// Hosts with different latencies, so the merged percentiles
+			// differ from those of any single report
+			ms := rng.ExpFloat64() * float64(i+1)
+			updateStats(&stats, ms)
+			reference.record(ms)
+		}
+		reports[i].Operations = []OperationReport{newOperationReport("SET", &stats, time.Second)}
+	}
+
+	merged, err := mergeReports(reports)
+	if err != nil {
+		t.Fatal(err)
+	}
+	op := merged.Operations[0]
+	for _, c := range []struct {
+		p   float64
+		got float64
+	}{{50, op.P50Ms}, {95, op.P95Ms}, {99, op.P99Ms}, {99.9, op.P999Ms}} {
+		if want := reference.percentile(c.p); !withinHDR(c.got, want) {
+			t.Errorf("merged p%v = %v, want %v", c.p, c.got, want)
+		}
+	}
+
+	// Without a histogram in every report the percentiles are unknown
+	reports[1].Operations[0].Histogram = ""
+	if merged, err = mergeReports(reports); err != nil {
+		t.Fatal(err)
+	}
+	if op := merged.Operations[0]; op.P50Ms != 0 || op.P99Ms != 0 || op.Histogram != "" {
+		t.Errorf("partial merge: p50=%v p99=%v histogram=%q, want them left out", op.P50Ms, op.P99Ms, op.Histogram)
+	}
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+
+	"gopkg.in/yaml.v3"
+)
+
+// secretFlags are left out of -dump-config so the file can be kept with the
+// results.
+var secretFlags = map[string]bool{"pass": true}
+
+// resolvedConfig returns every flag with its resolved value. The operation
+// ratios are normalized, which reproduces the same mix when the values are
+// loaded again.
+func resolvedConfig(ratios opRatios) map[string]string {
+	config := map[string]string{}
+	flag.VisitAll(func(f *flag.Flag) {
+		if secretFlags[f.Name] || f.Name == "dump-config" || f.Name == "config" {
+			return
+		}
+		config[f.Name] = f.Value.String()
+	})
+
+	n := ratios.normalized()
+	for name, v := range map[string]float64{
+		"set": n.set, "get": n.get, "del": n.del,
+		"idletime": n.idletime, "freq": n.freq,
+		"lmpop": n.lmpop, "zmpop": n.zmpop,
+		"incr": n.incr, "hset": n.hset, "lpush": n.lpush,
+	} {
+		config[name] = strconv.FormatFloat(v, 'g', -1, 64)
+	}
+	return config
+}
+
+// dumpConfig writes the resolved configuration to path as a JSON object of
+// flag name to value.
+func dumpConfig(path string, ratios opRatios) error {
+	data, err := json.MarshalIndent(resolvedConfig(ratios), "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o644)
+}
+
+// Config is the contents of a -config file: flag names without the leading
+// dash mapped to their values, the same layout -dump-config writes. Keying
+// the file by flag name lets it set any knob the command line can, such as
+//
+//	addr: 10.0.0.5:6379
+//	clients: 50
+//	duration: 1m
+//	set: 0.8
+//	get: 0.2
+//	del: 0
+//	value-size: set=512
+//	distribution: zipfian
+type Config map[string]interface{}
+
+// loadConfig reads a YAML or JSON file (JSON is valid YAML) and applies its
+// values to the flags that were not given on the command line.
+func loadConfig(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var config Config
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+
+	explicit := map[string]bool{}
+	flag.Visit(func(f *flag.Flag) {
+		explicit[f.Name] = true
+	})
+	names := make([]string, 0, len(config))
+	for name := range config {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		f := flag.Lookup(name)
+		if f == nil || name == "config" {
+			return fmt.Errorf("%s: unknown setting %q", path, name)
+		}
+		if explicit[name] {
+			continue
+		}
+		var value string
+		switch v := config[name].(type) {
+		case map[string]interface{}, []interface{}:
+			return fmt.Errorf("%s: %s must be a single value", path, name)
+		case nil:
+			continue
+		default:
+			value = fmt.Sprint(v)
+		}
+		// Values equal to the current one are skipped, so a -dump-config
+		// file loads even where a flag rejects its own empty default
+		if value == f.Value.String() {
+			continue
+		}
+		if err := flag.Set(name, value); err != nil {
+			return fmt.Errorf("%s: %s: %w", path, name, err)
+		}
+	}
+	return nil
+}
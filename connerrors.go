@@ -0,0 +1,343 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// errPoolTimeout is the message of go-redis's internal pool timeout error,
+// which is not exported.
+const errPoolTimeout = "redis: connection pool timeout"
+
+// gapResolution is how often the time of the last successful command is
+// updated, and so the precision of the longest gap.
+const gapResolution = time.Millisecond
+
+// maxTimelineSeconds bounds the timeline of a run; errors of later seconds
+// are counted in its last entry.
+const maxTimelineSeconds = 7 * 24 * 3600
+
+// ConnErrorSecond counts the errors of one second of the run.
+type ConnErrorSecond struct {
+	Second        int `json:"second"`
+	DialErrors    int `json:"dial_errors"`
+	NetworkErrors int `json:"network_errors"`
+	PoolTimeouts  int `json:"pool_timeouts"`
+	CommandErrors int `json:"command_errors"`
+}
+
+// ConnErrorReport separates connection-level failures, which tend to cluster
+// around pool exhaustion, network partitions and failovers, from errors
+// returned by commands. Network errors are failures of established
+// connections, such as a reset or a server that went away. Reconnects
+// counts the connections dialed to replace closed ones, ForcedReconnects
+// those replaced because of -reconnect-on-error, and LongestGapMs is
+// the longest time without a successful command, which during a failover
+// test approximates the downtime. Timeline has one entry per second of the
+// run.
+type ConnErrorReport struct {
+	DialErrors       int               `json:"dial_errors"`
+	NetworkErrors    int               `json:"network_errors"`
+	PoolTimeouts     int               `json:"pool_timeouts"`
+	CommandErrors    int               `json:"command_errors"`
+	Reconnects       int               `json:"reconnects"`
+	ForcedReconnects int               `json:"forced_reconnects,omitempty"`
+	LongestGapMs     float64           `json:"longest_gap_ms"`
+	LongestGapAt     float64           `json:"longest_gap_at_sec"`
+	Timeline         []ConnErrorSecond `json:"timeline"`
+}
+
+// connErrorTracker is a go-redis hook that classifies failed commands by
+// the second of the run they failed in. It also wraps the dialer, to count
+// the connections dialed again after go-redis closed a broken one.
+type connErrorTracker struct {
+	mu         sync.Mutex
+	start      time.Time
+	seconds    []ConnErrorSecond
+	closed     int
+	reconnects int
+	forced     int
+	longestGap time.Duration
+	longestAt  time.Duration // When the longest gap started, from start
+	downSince  time.Time
+
+	lastSuccess int64       // UnixNano of the last successful command
+	down        atomic.Bool // Set by a connection error, until a command succeeds
+
+	// active is set from begin to report, so the commands of warmup,
+	// preload and the other setup steps are not counted in any run.
+	active atomic.Bool
+}
+
+// begin starts a new timeline for the next run.
+func (t *connErrorTracker) begin() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.start = time.Now()
+	t.seconds = nil
+	t.closed = 0
+	t.reconnects = 0
+	t.forced = 0
+	t.longestGap = 0
+	t.longestAt = 0
+	t.down.Store(false)
+	atomic.StoreInt64(&t.lastSuccess, t.start.UnixNano())
+	t.active.Store(true)
+}
+
+// dialer wraps base, or a dialer with the go-redis defaults when base is
+// nil, so that closing a connection and dialing its replacement during a
+// run counts as a reconnect.
+func (t *connErrorTracker) dialer(base func(ctx context.Context, network, addr string) (net.Conn, error)) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	if base == nil {
+		d := net.Dialer{Timeout: 5 * time.Second, KeepAlive: 5 * time.Minute}
+		base = d.DialContext
+	}
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		conn, err := base(ctx, network, addr)
+		if err != nil {
+			return nil, err
+		}
+		t.mu.Lock()
+		reconnect := t.closed > 0
+		if reconnect {
+			t.closed--
+			t.reconnects++
+		}
+		t.mu.Unlock()
+		if reconnect {
+			logger.Debug("Reconnected", "addr", addr)
+		}
+		return &trackedConn{Conn: conn, tracker: t}, nil
+	}
+}
+
+// forcedReconnect counts a connection closed by -reconnect-on-error. Its
+// replacement is counted as a reconnect when it is dialed.
+func (t *connErrorTracker) forcedReconnect() {
+	if !t.active.Load() {
+		return
+	}
+	t.mu.Lock()
+	t.forced++
+	t.mu.Unlock()
+}
+
+// trackedConn tells its tracker when go-redis closes it.
+type trackedConn struct {
+	net.Conn
+	tracker *connErrorTracker
+	once    sync.Once
+}
+
+func (c *trackedConn) Close() error {
+	c.once.Do(func() {
+		c.tracker.mu.Lock()
+		c.tracker.closed++
+		c.tracker.mu.Unlock()
+	})
+	return c.Conn.Close()
+}
+
+// succeeded extends the current stretch of successful commands, and if the
+// previous success was long enough ago, records the gap since then.
+func (t *connErrorTracker) succeeded() {
+	if t.down.Load() {
+		t.mu.Lock()
+		if t.down.Load() {
+			t.down.Store(false)
+			logger.Info("Connection to Redis restored", "down_for", time.Since(t.downSince).Round(time.Millisecond))
+		}
+		t.mu.Unlock()
+	}
+	if !t.active.Load() {
+		return
+	}
+	now := time.Now().UnixNano()
+	last := atomic.LoadInt64(&t.lastSuccess)
+	if time.Duration(now-last) < gapResolution || !atomic.CompareAndSwapInt64(&t.lastSuccess, last, now) {
+		return
+	}
+	t.mu.Lock()
+	t.recordGap(last, now)
+	t.mu.Unlock()
+}
+
+// recordGap keeps the gap from last to now, as UnixNano, if it is the
+// longest so far. t.mu must be held.
+func (t *connErrorTracker) recordGap(last, now int64) {
+	if gap := time.Duration(now - last); gap > t.longestGap {
+		t.longestGap = gap
+		t.longestAt = time.Unix(0, last).Sub(t.start)
+	}
+}
+
+// isNetworkError reports whether err is the failure of an established
+// connection rather than an error reply to the command.
+func isNetworkError(err error) bool {
+	var opErr *net.OpError
+	return errors.As(err, &opErr) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, net.ErrClosed) || errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.EPIPE)
+}
+
+// report returns the counts since begin, with a timeline entry for every
+// second up to d, and ends the run.
+func (t *connErrorTracker) report(d time.Duration) ConnErrorReport {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.active.Store(false)
+
+	n := min(int(d.Seconds()), maxTimelineSeconds+1)
+	if len(t.seconds) > n {
+		n = len(t.seconds)
+	}
+	// A run that ends without the server coming back ends the gap
+	t.recordGap(atomic.LoadInt64(&t.lastSuccess), t.start.Add(d).UnixNano())
+	r := ConnErrorReport{
+		Timeline:         make([]ConnErrorSecond, n),
+		Reconnects:       t.reconnects,
+		ForcedReconnects: t.forced,
+		LongestGapMs:     t.longestGap.Seconds() * 1000,
+		LongestGapAt:     t.longestAt.Seconds(),
+	}
+	for i := range r.Timeline {
+		r.Timeline[i].Second = i
+	}
+	for _, s := range t.seconds {
+		r.Timeline[s.Second] = s
+		r.DialErrors += s.DialErrors
+		r.NetworkErrors += s.NetworkErrors
+		r.PoolTimeouts += s.PoolTimeouts
+		r.CommandErrors += s.CommandErrors
+	}
+	return r
+}
+
+func (t *connErrorTracker) record(err error) {
+	if err == nil || err == redis.Nil {
+		t.succeeded()
+		return
+	}
+	var opErr *net.OpError
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	// Outside a run failures are only logged, into a scratch entry
+	s := &ConnErrorSecond{}
+	if t.active.Load() {
+		sec := min(max(int(time.Since(t.start).Seconds()), 0), maxTimelineSeconds)
+		for len(t.seconds) <= sec {
+			t.seconds = append(t.seconds, ConnErrorSecond{Second: len(t.seconds)})
+		}
+		s = &t.seconds[sec]
+	}
+	switch {
+	case err.Error() == errPoolTimeout:
+		s.PoolTimeouts++
+		logger.Debug("Pool timeout", "error", err)
+	case errors.As(err, &opErr) && opErr.Op == "dial":
+		s.DialErrors++
+		t.lost(err)
+	case isNetworkError(err):
+		s.NetworkErrors++
+		t.lost(err)
+	default:
+		s.CommandErrors++
+		logger.Debug("Command failed", "error", err)
+	}
+}
+
+// lost logs the first connection error after a successful command; the
+// following ones are only logged at debug level until the connection is
+// restored. t.mu must be held.
+func (t *connErrorTracker) lost(err error) {
+	if t.down.Load() {
+		logger.Debug("Connection error", "error", err)
+		return
+	}
+	t.down.Store(true)
+	t.downSince = time.Now()
+	logger.Warn("Lost connection to Redis", "error", err)
+}
+
+func (t *connErrorTracker) BeforeProcess(ctx context.Context, cmd redis.Cmder) (context.Context, error) {
+	return ctx, nil
+}
+
+func (t *connErrorTracker) AfterProcess(ctx context.Context, cmd redis.Cmder) error {
+	t.record(cmd.Err())
+	return nil
+}
+
+func (t *connErrorTracker) BeforeProcessPipeline(ctx context.Context, cmds []redis.Cmder) (context.Context, error) {
+	return ctx, nil
+}
+
+func (t *connErrorTracker) AfterProcessPipeline(ctx context.Context, cmds []redis.Cmder) error {
+	for _, cmd := range cmds {
+		t.record(cmd.Err())
+	}
+	return nil
+}
+
+// printConnErrors prints the error counts, the reconnects and the longest
+// gap between successful commands, and for connection errors the seconds
+// they occurred in.
+func printConnErrors(r ConnErrorReport) {
+	fmt.Printf("Connection errors: dial=%d, network=%d, pool timeouts=%d (command errors: %d)\n",
+		r.DialErrors, r.NetworkErrors, r.PoolTimeouts, r.CommandErrors)
+	forced := ""
+	if reconnectErrors != nil {
+		forced = fmt.Sprintf(" (%d forced by -reconnect-on-error)", r.ForcedReconnects)
+	}
+	fmt.Printf("Reconnects: %d%s, longest gap without a successful command: %.1f ms (at %.1fs)\n",
+		r.Reconnects, forced, r.LongestGapMs, r.LongestGapAt)
+	if r.DialErrors+r.NetworkErrors+r.PoolTimeouts == 0 {
+		return
+	}
+	fmt.Println("Connection error timeline:")
+	for _, s := range r.Timeline {
+		if s.DialErrors+s.NetworkErrors+s.PoolTimeouts > 0 {
+			fmt.Printf("  %3ds: dial=%d, network=%d, pool timeouts=%d\n", s.Second, s.DialErrors, s.NetworkErrors, s.PoolTimeouts)
+		}
+	}
+}
+
+// connErrorsCSV writes the timeline of every run to -conn-errors-csv, with
+// runs numbered from 1 in the order they were executed.
+type connErrorsCSV struct {
+	w    *csv.Writer
+	runs int
+}
+
+func newConnErrorsCSV(w io.Writer) *connErrorsCSV {
+	c := &connErrorsCSV{w: csv.NewWriter(w)}
+	c.w.Write([]string{"run", "second", "dial_errors", "pool_timeouts", "command_errors", "network_errors"})
+	return c
+}
+
+func (c *connErrorsCSV) write(r ConnErrorReport) error {
+	c.runs++
+	for _, s := range r.Timeline {
+		c.w.Write([]string{
+			strconv.Itoa(c.runs),
+			strconv.Itoa(s.Second),
+			strconv.Itoa(s.DialErrors),
+			strconv.Itoa(s.PoolTimeouts),
+			strconv.Itoa(s.CommandErrors),
+			strconv.Itoa(s.NetworkErrors),
+		})
+	}
+	c.w.Flush()
+	return c.w.Error()
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// warmConnections opens the client's full pool before the measured window.
+// go-redis dials lazily, so without this the first operations of a run pay
+// for connection setup. Each connection is pinned with Conn and pinged, and
+// all of them are released back to the pool once every one is open.
+func warmConnections(ctx context.Context, client *redis.Client) (int, time.Duration, error) {
+	size := client.Options().PoolSize
+	conns := make([]*redis.Conn, 0, size)
+	defer func() {
+		for _, c := range conns {
+			c.Close()
+		}
+	}()
+
+	start := time.Now()
+	for i := 0; i < size; i++ {
+		c := client.Conn(ctx)
+		conns = append(conns, c)
+		if err := c.Ping(ctx).Err(); err != nil {
+			return i, time.Since(start), err
+		}
+	}
+	return size, time.Since(start), nil
+}
+
+// printConnectionWarmup warms the pool of each distinct client, or of each
+// master node with -cluster, and reports how long it took.
+func printConnectionWarmup(ctx context.Context, clients opClients) {
+	names := []string{"shared"}
+	pools := []benchClient{clients.set}
+	if isolateOps {
+		names = []string{"SET", "GET", "DEL"}
+		pools = []benchClient{clients.set, clients.get, clients.del}
+	}
+
+	for i, client := range pools {
+		nodes, err := nodeClients(ctx, client)
+		if err != nil {
+			fmt.Printf("Connection warmup (%s pool): failed to list nodes: %v\n", names[i], err)
+			continue
+		}
+		var opened int
+		var elapsed time.Duration
+		for _, node := range nodes {
+			var n int
+			var d time.Duration
+			n, d, err = warmConnections(ctx, node)
+			opened += n
+			elapsed += d
+			if err != nil {
+				break
+			}
+		}
+		if err != nil {
+			fmt.Printf("Connection warmup (%s pool): failed after %d connections in %v: %v\n", names[i], opened, elapsed, err)
+			continue
+		}
+		fmt.Printf("Connection warmup (%s pool): opened %d connections in %v\n", names[i], opened, elapsed)
+	}
+}
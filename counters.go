@@ -0,0 +1,57 @@
+package main
+
+import "sync/atomic"
+
+// workerCounters are the operation counts of one worker. Only the worker
+// writes them, with atomic adds, so the progress reporter, the throughput
+// sampler and -adaptive-mix can read them during the run without a lock
+// shared by all workers. The padding keeps the counters of neighbouring
+// workers on separate cache lines.
+type workerCounters struct {
+	set, get, del       int64
+	pipeline            int64
+	lpush, blpop        int64
+	incr, hset          int64
+	command             int64
+	published, received int64
+	scan                int64
+	scanIterations      int64 // Full SCAN iterations of -mode scan
+	scanKeys            int64 // Keys returned by completed iterations
+	scanKeysCalls       int64 // SCAN calls of completed iterations
+	setBytes, getBytes  int64 // Data sizes include key and value
+	volatileSets        int64
+	getHits             int64
+	waitAOFAchieved     int64
+	queueTimeouts       int64
+	_                   [32]byte
+}
+
+func loadCount(n *int64) int {
+	return int(atomic.LoadInt64(n))
+}
+
+// operations returns the number of operations the worker has completed.
+func (c *workerCounters) operations() int {
+	return loadCount(&c.set) + loadCount(&c.get) + loadCount(&c.del) +
+		loadCount(&c.pipeline) + loadCount(&c.lpush) + loadCount(&c.blpop) +
+		loadCount(&c.incr) + loadCount(&c.hset) + loadCount(&c.command) +
+		loadCount(&c.published) + loadCount(&c.received) + loadCount(&c.scan)
+}
+
+// requested returns the number of completed operations of the worker that
+// count toward -requests: all but the messages and items it received as a
+// subscriber or queue consumer.
+func (c *workerCounters) requested() int {
+	return c.operations() - loadCount(&c.received) - loadCount(&c.blpop)
+}
+
+// setGetDel sums the SET, GET and DEL counts of all workers.
+func setGetDel(counters []workerCounters) (set, get, del int) {
+	for i := range counters {
+		c := &counters[i]
+		set += loadCount(&c.set)
+		get += loadCount(&c.get)
+		del += loadCount(&c.del)
+	}
+	return set, get, del
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// parseDatabases parses -dbs: a count such as "4" for databases 0 to 3, or
+// a comma-separated list of database numbers such as "0,5,9".
+func parseDatabases(spec string) ([]int, error) {
+	if !strings.Contains(spec, ",") {
+		n, err := strconv.Atoi(strings.TrimSpace(spec))
+		if err != nil || n < 1 {
+			return nil, fmt.Errorf("expected a count of at least 1 or a comma-separated list of database numbers")
+		}
+		dbs := make([]int, n)
+		for i := range dbs {
+			dbs[i] = i
+		}
+		return dbs, nil
+	}
+
+	var dbs []int
+	seen := map[int]bool{}
+	for _, part := range strings.Split(spec, ",") {
+		db, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil || db < 0 {
+			return nil, fmt.Errorf("invalid database number %q", part)
+		}
+		if seen[db] {
+			return nil, fmt.Errorf("database %d listed twice", db)
+		}
+		seen[db] = true
+		dbs = append(dbs, db)
+	}
+	return dbs, nil
+}
+
+// databaseClients opens a client for each of -dbs, so workers can be
+// spread across the databases round-robin. Each client is pinged, which
+// also checks the server has that many databases. close releases them all.
+func databaseClients(ctx context.Context, opts *redis.Options, dbs []int) (clients []opClients, close func(), err error) {
+	close = func() {
+		for _, c := range clients {
+			c.set.Close()
+		}
+	}
+	for _, db := range dbs {
+		o := *opts
+		o.DB = db
+		c := newBenchClient(&o)
+		clients = append(clients, opClients{set: c, get: c, del: c})
+		if err := c.Ping(ctx).Err(); err != nil {
+			close()
+			return nil, nil, fmt.Errorf("database %d: %w", db, err)
+		}
+	}
+	return clients, close, nil
+}
+
+// printDBTotals prints the operations completed against each of -dbs, for
+// checking that the workers spread the load evenly.
+func (r *benchmarkRun) printDBTotals() {
+	if len(databases) == 0 {
+		return
+	}
+	totals := make([]int, len(databases))
+	all := 0
+	for i := range r.counters {
+		ops := r.counters[i].operations()
+		totals[i%len(databases)] += ops
+		all += ops
+	}
+	fmt.Println("Per-database operations:")
+	for i, db := range databases {
+		share := 0.0
+		if all > 0 {
+			share = float64(totals[i]) / float64(all) * 100
+		}
+		fmt.Printf("  DB %-5d %12d %8.2f%%\n", db, totals[i], share)
+	}
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"context"
+	"net"
+	"time"
+)
+
+// delayConn adds a fixed delay before every write, so each request/response
+// round trip pays the extra latency of a distant endpoint.
+type delayConn struct {
+	net.Conn
+	delay time.Duration
+}
+
+func (c *delayConn) Write(b []byte) (int, error) {
+	time.Sleep(c.delay)
+	return c.Conn.Write(b)
+}
+
+// delayDialer returns a go-redis dialer whose connections are wrapped in a
+// delayConn.
+func delayDialer(delay time.Duration) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		var d net.Dialer
+		conn, err := d.DialContext(ctx, network, addr)
+		if err != nil {
+			return nil, err
+		}
+		return &delayConn{Conn: conn, delay: delay}, nil
+	}
+}
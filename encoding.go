@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// encodingPhase describes the values written in one -encoding-compare phase.
+// Redis stores strings that parse as 64-bit integers as int, strings of up
+// to 44 bytes as embstr and anything longer as raw.
+type encodingPhase struct {
+	encoding  string
+	size      int
+	intValues bool
+}
+
+var encodingPhases = []encodingPhase{
+	{encoding: "int", intValues: true},
+	{encoding: "embstr", size: 32},
+	{encoding: "raw", size: defaultValueSize},
+}
+
+// encodingSampleSize is how many keys are checked with OBJECT ENCODING after
+// each phase.
+const encodingSampleSize = 20
+
+// runEncodingComparison runs the workload once per string encoding, clearing
+// the keys in between so each phase only reads its own values, and prints a
+// comparison of the phases.
+func runEncodingComparison(ctx context.Context, ratios opRatios, clients opClients, keys []string, tmpl *template.Template) {
+	runs := make([]*benchmarkRun, len(encodingPhases))
+	confirmed := make([]string, len(encodingPhases))
+	for i, phase := range encodingPhases {
+		if err := clearKeys(ctx, clients.set, keys); err != nil {
+			logger.Error("Failed to clear keys, skipping the remaining phases", "phase", phase.encoding, "error", err)
+			return
+		}
+
+		fmt.Printf("\nEncoding phase %d/%d: %s values\n", i+1, len(encodingPhases), phase.encoding)
+		run := newBenchmarkRun(ratios, numClients)
+		run.valueSize = phase.size
+		run.intValues = phase.intValues
+		run.execute(ctx, clients, keys, testDuration)
+		run.printSummary(clients, tmpl)
+		sendResults(run)
+		if run.interrupted {
+			return
+		}
+
+		encodings, err := sampleEncodings(ctx, clients.get, keys)
+		if err != nil {
+			fmt.Printf("OBJECT ENCODING check failed: %v\n", err)
+			encodings = "unknown"
+		} else {
+			fmt.Printf("OBJECT ENCODING (sample of up to %d keys): %s\n", encodingSampleSize, encodings)
+		}
+		confirmed[i] = encodings
+		runs[i] = run
+	}
+
+	fmt.Println("\nEncoding comparison:")
+	fmt.Printf("%-8s %-24s %14s %14s %12s %12s\n",
+		"Phase", "Confirmed encoding", "SET ops/sec", "GET ops/sec", "SET avg (ms)", "GET avg (ms)")
+	for i, run := range runs {
+		report := run.report()
+		set, get := report.Operations[0], report.Operations[1]
+		fmt.Printf("%-8s %-24s %14.2f %14.2f %12.3f %12.3f\n",
+			encodingPhases[i].encoding, confirmed[i], set.OpsPerSec, get.OpsPerSec, set.AvgMs, get.AvgMs)
+	}
+}
+
+// clearKeys deletes the benchmark keys in pipelined batches.
+func clearKeys(ctx context.Context, rdb benchClient, keys []string) error {
+	const batch = 1000
+	for start := 0; start < len(keys); start += batch {
+		end := start + batch
+		if end > len(keys) {
+			end = len(keys)
+		}
+		if err := rdb.Del(ctx, keys[start:end]...).Err(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sampleEncodings asks OBJECT ENCODING for the first keys that exist and
+// summarizes the answers, e.g. "embstr=20".
+func sampleEncodings(ctx context.Context, rdb benchClient, keys []string) (string, error) {
+	counts := map[string]int{}
+	sampled := 0
+	for _, key := range keys {
+		if sampled == encodingSampleSize {
+			break
+		}
+		enc, err := rdb.ObjectEncoding(ctx, key).Result()
+		if err == redis.Nil {
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		counts[enc]++
+		sampled++
+	}
+	if sampled == 0 {
+		return "no keys found", nil
+	}
+
+	var parts []string
+	for enc, n := range counts {
+		parts = append(parts, fmt.Sprintf("%s=%d", enc, n))
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, " "), nil
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// exitErrorRate is the exit status when a run's error rate exceeds
+// -fail-on-error.
+const exitErrorRate = 4
+
+// errorRateExceeded is set when any run of the invocation failed more
+// operations than -fail-on-error allows.
+var errorRateExceeded bool
+
+// recordError counts a failed operation. The failure is not part of the
+// latency statistics.
+func recordError(stats *operationStats) {
+	stats.mu.Lock()
+	defer stats.mu.Unlock()
+	stats.errors++
+	if stats.metrics != nil {
+		stats.metrics.errors.Inc()
+	}
+}
+
+// errorOps returns the operations whose failures are counted: SET, GET and
+// DEL, and OBJECT IDLETIME, OBJECT FREQ, LMPOP, ZMPOP, INCR, HSET and LPUSH
+// when enabled, the -command or -command-pipeline-file alone, LPUSH and
+// BLPOP with -queue-consumers, publishing and receiving in -mode pubsub, or
+// SCAN in -mode scan.
+func (r *benchmarkRun) errorOps() []typedOp {
+	if commandTemplate != nil {
+		return []typedOp{{commandName(), 1, &r.stats.command}}
+	}
+	if pipelineCommands != nil {
+		return []typedOp{{"PIPELINE", 1, &r.stats.pipeline}}
+	}
+	if queueConsumers > 0 {
+		return []typedOp{{"LPUSH", 1, &r.stats.lpush}, {"BLPOP", 1, &r.stats.blpop}}
+	}
+	if benchMode == modePubSub {
+		return []typedOp{{"PUBLISH", 1, &r.stats.publish}, {"DELIVERY", 1, &r.stats.deliver}}
+	}
+	if benchMode == modeScan {
+		return []typedOp{{"SCAN", 1, &r.stats.scan}}
+	}
+	ops := []typedOp{
+		{"SET", r.ratios.set, &r.stats.set},
+		{"GET", r.ratios.get, &r.stats.get},
+		{"DEL", r.ratios.del, &r.stats.del},
+	}
+	for _, op := range []typedOp{
+		{"OBJECT IDLETIME", r.ratios.idletime, &r.stats.obj.idleLatency},
+		{"OBJECT FREQ", r.ratios.freq, &r.stats.obj.freqLatency},
+		{"LMPOP", r.ratios.lmpop, &r.stats.lmpop},
+		{"ZMPOP", r.ratios.zmpop, &r.stats.zmpop},
+	} {
+		if op.ratio > 0 {
+			ops = append(ops, op)
+		}
+	}
+	return append(ops, r.typedOps()...)
+}
+
+// errorRate returns the number of failed operations and their share of all
+// attempted ones. A GET of a missing key is not a failure.
+func (r *benchmarkRun) errorRate() (int, float64) {
+	errors := 0
+	for _, op := range r.errorOps() {
+		op.stats.mu.Lock()
+		errors += op.stats.errors
+		op.stats.mu.Unlock()
+	}
+	attempted := r.completed() + errors
+	if attempted == 0 {
+		return 0, 0
+	}
+	return errors, float64(errors) / float64(attempted)
+}
+
+// checkErrorRate records whether the run breached -fail-on-error.
+func (r *benchmarkRun) checkErrorRate() {
+	if failOnError < 0 {
+		return
+	}
+	if _, rate := r.errorRate(); rate > failOnError {
+		errorRateExceeded = true
+	}
+}
+
+// printErrors prints the failed operations per type and the overall error
+// rate.
+func (r *benchmarkRun) printErrors() {
+	var parts []string
+	for _, op := range r.errorOps() {
+		if op.ratio == 0 {
+			continue
+		}
+		op.stats.mu.Lock()
+		parts = append(parts, fmt.Sprintf("%s=%d", op.name, op.stats.errors))
+		op.stats.mu.Unlock()
+	}
+	errors, rate := r.errorRate()
+	fmt.Printf("Errors: %s (total %d, error rate %.2f%%)\n", strings.Join(parts, ", "), errors, rate*100)
+	if failOnError >= 0 && rate > failOnError {
+		fmt.Printf("Error rate %.2f%% exceeds -fail-on-error %.2f%%\n", rate*100, failOnError*100)
+	}
+}
+
+// exitOnErrorRate exits with exitErrorRate if a run breached -fail-on-error.
+// main calls it once run has returned, so the run's deferred cleanup has
+// already happened.
+func exitOnErrorRate() {
+	if errorRateExceeded {
+		exit(exitErrorRate)
+	}
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+var errInjectedFault = errors.New("injected fault")
+
+// faultHook is a go-redis hook that fails or delays a fraction of commands
+// on the client side, independently of the server. It uses its own seeded
+// generator so a given -fault-seed always faults the same command sequence.
+type faultHook struct {
+	rate  float64
+	mode  string
+	delay time.Duration
+
+	mu       sync.Mutex
+	rng      *rand.Rand
+	failures int
+	delays   int
+}
+
+func newFaultHook(rate float64, mode string, delay time.Duration, seed int64) *faultHook {
+	return &faultHook{
+		rate:  rate,
+		mode:  mode,
+		delay: delay,
+		rng:   rand.New(rand.NewSource(seed)),
+	}
+}
+
+// inject decides the fault for one command (or pipeline) and applies it.
+func (h *faultHook) inject() error {
+	h.mu.Lock()
+	if h.rng.Float64() >= h.rate {
+		h.mu.Unlock()
+		return nil
+	}
+	fail := h.mode == "fail" || (h.mode == "mixed" && h.rng.Intn(2) == 0)
+	if fail {
+		h.failures++
+	} else {
+		h.delays++
+	}
+	h.mu.Unlock()
+
+	if fail {
+		return errInjectedFault
+	}
+	time.Sleep(h.delay)
+	return nil
+}
+
+func (h *faultHook) BeforeProcess(ctx context.Context, cmd redis.Cmder) (context.Context, error) {
+	return ctx, h.inject()
+}
+
+func (h *faultHook) AfterProcess(ctx context.Context, cmd redis.Cmder) error {
+	return nil
+}
+
+func (h *faultHook) BeforeProcessPipeline(ctx context.Context, cmds []redis.Cmder) (context.Context, error) {
+	return ctx, h.inject()
+}
+
+func (h *faultHook) AfterProcessPipeline(ctx context.Context, cmds []redis.Cmder) error {
+	return nil
+}
+
+// counts returns the number of injected failures and delays so far.
+func (h *faultHook) counts() (failures, delays int) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.failures, h.delays
+}
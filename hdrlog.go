@@ -0,0 +1,160 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/HdrHistogram/hdrhistogram-go"
+	"github.com/go-redis/redis/v8"
+)
+
+// HDR histograms in the log record nanoseconds from 1µs to 60s with three
+// significant digits, the usual setup for HdrHistogram latency logs.
+const (
+	hdrLowest  = int64(time.Microsecond)
+	hdrHighest = int64(60 * time.Second)
+	hdrDigits  = 3
+)
+
+type hdrStartKey struct{}
+
+// hdrLogger is a go-redis hook that records the latency of every command in
+// a per-command histogram and writes the histograms to a file in the
+// HdrHistogram interval log format at a fixed interval, tagged with the
+// command name (or PIPELINE).
+type hdrLogger struct {
+	mu    sync.Mutex
+	hists map[string]*hdrhistogram.Histogram
+	start time.Time
+	last  time.Time
+
+	f    *os.File
+	w    *bufio.Writer
+	stop chan struct{}
+	done chan struct{}
+}
+
+func newHDRLogger(path string) (*hdrLogger, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	l := &hdrLogger{
+		hists: make(map[string]*hdrhistogram.Histogram),
+		start: now,
+		last:  now,
+		f:     f,
+		w:     bufio.NewWriter(f),
+	}
+
+	lw := hdrhistogram.NewHistogramLogWriter(l.w)
+	lw.OutputComment("[Logged with another-redis-benchmark]")
+	lw.OutputLogFormatVersion()
+	lw.OutputStartTime(now.UnixMilli())
+	lw.OutputBaseTime(now.UnixMilli())
+	lw.OutputLegend()
+	return l, l.w.Flush()
+}
+
+func (l *hdrLogger) record(tag string, d time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	h, ok := l.hists[tag]
+	if !ok {
+		h = hdrhistogram.New(hdrLowest, hdrHighest, hdrDigits)
+		l.hists[tag] = h
+	}
+	// Values beyond the range are dropped rather than failing the command
+	_ = h.RecordValue(int64(d))
+}
+
+func (l *hdrLogger) BeforeProcess(ctx context.Context, cmd redis.Cmder) (context.Context, error) {
+	return context.WithValue(ctx, hdrStartKey{}, time.Now()), nil
+}
+
+func (l *hdrLogger) AfterProcess(ctx context.Context, cmd redis.Cmder) error {
+	if start, ok := ctx.Value(hdrStartKey{}).(time.Time); ok {
+		if err := cmd.Err(); err == nil || err == redis.Nil {
+			l.record(strings.ToUpper(cmd.Name()), time.Since(start))
+		}
+	}
+	return nil
+}
+
+func (l *hdrLogger) BeforeProcessPipeline(ctx context.Context, cmds []redis.Cmder) (context.Context, error) {
+	return context.WithValue(ctx, hdrStartKey{}, time.Now()), nil
+}
+
+func (l *hdrLogger) AfterProcessPipeline(ctx context.Context, cmds []redis.Cmder) error {
+	if start, ok := ctx.Value(hdrStartKey{}).(time.Time); ok {
+		l.record("PIPELINE", time.Since(start))
+	}
+	return nil
+}
+
+// writeInterval writes one line per command recorded since the previous
+// interval and starts new histograms. Timestamps are seconds since the
+// start of the log and the interval max is in milliseconds.
+func (l *hdrLogger) writeInterval(now time.Time) error {
+	l.mu.Lock()
+	hists, start := l.hists, l.last
+	l.hists = make(map[string]*hdrhistogram.Histogram)
+	l.last = now
+	l.mu.Unlock()
+
+	tags := make([]string, 0, len(hists))
+	for tag := range hists {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+	for _, tag := range tags {
+		h := hists[tag]
+		payload, err := h.Encode(hdrhistogram.V2CompressedEncodingCookieBase)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(l.w, "Tag=%s,%.3f,%.3f,%.3f,%s\n", tag,
+			start.Sub(l.start).Seconds(), now.Sub(start).Seconds(),
+			float64(h.Max())/float64(time.Millisecond), payload)
+	}
+	return l.w.Flush()
+}
+
+// run writes an interval every interval until close is called.
+func (l *hdrLogger) run(interval time.Duration) {
+	l.stop = make(chan struct{})
+	l.done = make(chan struct{})
+	go func() {
+		defer close(l.done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-l.stop:
+				return
+			case now := <-ticker.C:
+				if err := l.writeInterval(now); err != nil {
+					logger.Error("Failed to write HDR histogram log", "error", err)
+				}
+			}
+		}
+	}()
+}
+
+// close writes the final partial interval and closes the file.
+func (l *hdrLogger) close() error {
+	close(l.stop)
+	<-l.done
+	if err := l.writeInterval(time.Now()); err != nil {
+		l.f.Close()
+		return err
+	}
+	return l.f.Close()
+}
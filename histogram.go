@@ -0,0 +1,103 @@
+package main
+
+import "github.com/HdrHistogram/hdrhistogram-go"
+
+// latencyHistogram records latencies in milliseconds in an HdrHistogram
+// covering 1µs to 60s with three significant digits, the same range as the
+// -hdr-log histograms. Percentiles are accurate to 0.1% up to the tail, and
+// its memory use does not depend on the number of samples. The underlying
+// histogram (about 150 KB) is only allocated by the first sample, so the
+// zero value is ready to use and operations that never run cost nothing.
+type latencyHistogram struct {
+	h     *hdrhistogram.Histogram
+	total uint64
+}
+
+func newHDRHistogram() *hdrhistogram.Histogram {
+	return hdrhistogram.New(hdrLowest, hdrHighest, hdrDigits)
+}
+
+func (h *latencyHistogram) record(ms float64) {
+	if h.h == nil {
+		h.h = newHDRHistogram()
+	}
+	// Latencies beyond the range are counted as the highest trackable value
+	ns := min(max(int64(ms*1e6), 0), hdrHighest)
+	h.h.RecordValue(ns)
+	h.total++
+}
+
+// percentile returns the p-th percentile (0-100) in milliseconds, or 0 when
+// the histogram is empty.
+func (h *latencyHistogram) percentile(p float64) float64 {
+	if h.total == 0 {
+		return 0
+	}
+	return float64(h.h.ValueAtQuantile(p)) / 1e6
+}
+
+// trimmedMean returns the mean in milliseconds after dropping pct percent of
+// the samples from each end, or 0 when none are left. Each sample counts as
+// the middle of its bucket, so the mean has the precision of the
+// percentiles.
+func (h *latencyHistogram) trimmedMean(pct float64) float64 {
+	drop := int64(float64(h.total) * pct / 100)
+	left := int64(h.total) - 2*drop
+	if left <= 0 {
+		return 0
+	}
+	kept, sum := left, 0.0
+	for _, b := range h.h.Distribution() {
+		n := b.Count
+		skip := min(drop, n)
+		drop -= skip
+		n = min(n-skip, left)
+		left -= n
+		sum += float64(n) * float64(b.From+b.To) / 2
+		if left == 0 {
+			break
+		}
+	}
+	return sum / float64(kept) / 1e6
+}
+
+// merge adds the samples of o to h.
+func (h *latencyHistogram) merge(o *latencyHistogram) {
+	if o.total == 0 {
+		return
+	}
+	if h.h == nil {
+		h.h = newHDRHistogram()
+	}
+	h.h.Merge(o.h)
+	h.total += o.total
+}
+
+// encode returns the histogram in the compressed base64 form of the -hdr-log
+// lines, or "" when it is empty.
+func (h *latencyHistogram) encode() (string, error) {
+	if h.total == 0 {
+		return "", nil
+	}
+	payload, err := h.h.Encode(hdrhistogram.V2CompressedEncodingCookieBase)
+	return string(payload), err
+}
+
+// decodeLatencyHistogram reverses encode.
+func decodeLatencyHistogram(s string) (latencyHistogram, error) {
+	if s == "" {
+		return latencyHistogram{}, nil
+	}
+	h, err := hdrhistogram.Decode([]byte(s))
+	if err != nil {
+		return latencyHistogram{}, err
+	}
+	return latencyHistogram{h: h, total: uint64(h.TotalCount())}, nil
+}
+
+// reset discards every sample, so percentiles afterwards only reflect
+// samples recorded after the reset. A copy taken before the reset keeps the
+// old samples.
+func (h *latencyHistogram) reset() {
+	*h = latencyHistogram{}
+}
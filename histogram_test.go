@@ -0,0 +1,111 @@
+package main
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+// withinHDR reports whether got is within the precision of the histograms,
+// three significant digits, of want.
+func withinHDR(got, want float64) bool {
+	return math.Abs(got-want) <= want*1e-3
+}
+
+func TestLatencyHistogramReset(t *testing.T) {
+	var h latencyHistogram
+	for i := 0; i < 1000; i++ {
+		h.record(100)
+	}
+	before := h
+	h.reset()
+
+	if h.total != 0 || h.percentile(99) != 0 {
+		t.Fatalf("after reset: total=%d p99=%v, want an empty histogram", h.total, h.percentile(99))
+	}
+	for i := 0; i < 10; i++ {
+		h.record(1)
+	}
+	if h.total != 10 {
+		t.Errorf("total after reset = %d, want 10", h.total)
+	}
+	for _, p := range []float64{50, 99, 100} {
+		if got := h.percentile(p); !withinHDR(got, 1) {
+			t.Errorf("p%v after reset = %v ms, want 1 ms", p, got)
+		}
+	}
+
+	// A copy taken before the reset, like the interval of thresholdMonitor
+	// and the window of bgsaveProbe, keeps the old samples
+	if before.total != 1000 {
+		t.Errorf("copy total = %d, want 1000", before.total)
+	}
+	if got := before.percentile(99); !withinHDR(got, 100) {
+		t.Errorf("copy p99 = %v ms, want 100 ms", got)
+	}
+}
+
+func TestRunStatsMergeMatchesSingleHistogram(t *testing.T) {
+	const workers = 8
+	rng := rand.New(rand.NewSource(1))
+	reference := newRunStats()
+	perWorker := make([]*runStats, workers)
+	for i := range perWorker {
+		perWorker[i] = newRunStats()
+	}
+	for i := 0; i < 100000; i++ {
+		// Exponential latencies with a long tail, from microseconds to
+		// hundreds of milliseconds
+		ms := rng.ExpFloat64() * 2
+		updateStats(&reference.set, ms)
+		updateStats(&perWorker[i%workers].set, ms)
+	}
+
+	merged := newRunStats()
+	for _, w := range perWorker {
+		merged.merge(w)
+	}
+
+	if merged.set.count != reference.set.count || merged.set.hist.total != reference.set.hist.total {
+		t.Fatalf("merged count=%d total=%d, want %d and %d",
+			merged.set.count, merged.set.hist.total, reference.set.count, reference.set.hist.total)
+	}
+	if merged.set.minTime != reference.set.minTime || merged.set.maxTime != reference.set.maxTime {
+		t.Errorf("merged min/max = %v/%v, want %v/%v",
+			merged.set.minTime, merged.set.maxTime, reference.set.minTime, reference.set.maxTime)
+	}
+	for _, p := range []float64{50, 99, 99.9} {
+		got, want := merged.set.hist.percentile(p), reference.set.hist.percentile(p)
+		if !withinHDR(got, want) {
+			t.Errorf("merged p%v = %v ms, want %v ms", p, got, want)
+		}
+	}
+}
+
+func TestLatencyHistogramTrimmedMean(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	var h latencyHistogram
+	samples := make([]float64, 100000)
+	for i := range samples {
+		samples[i] = rng.ExpFloat64() * 2
+		h.record(samples[i])
+	}
+	sort.Float64s(samples)
+
+	for _, pct := range []float64{0, 1, 5, 25} {
+		drop := int(float64(len(samples)) * pct / 100)
+		kept := samples[drop : len(samples)-drop]
+		want := 0.0
+		for _, s := range kept {
+			want += s
+		}
+		want /= float64(len(kept))
+		if got := h.trimmedMean(pct); !withinHDR(got, want) {
+			t.Errorf("trimmedMean(%v) = %v, want %v", pct, got, want)
+		}
+	}
+	if got := h.trimmedMean(50); got != 0 {
+		t.Errorf("trimmedMean(50) = %v, want 0 with every sample dropped", got)
+	}
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+// historySchema is the stable layout of the -history-db file. A run is one
+// row in runs; its operations are rows in operations keyed by run_id. The
+// full resolved configuration, tags and report are kept as JSON as well so
+// fields added later are not lost.
+const historySchema = `
+CREATE TABLE IF NOT EXISTS runs (
+	id           INTEGER PRIMARY KEY AUTOINCREMENT,
+	finished_at  TEXT NOT NULL,
+	addr         TEXT NOT NULL,
+	clients      INTEGER NOT NULL,
+	keys         INTEGER NOT NULL,
+	duration_sec REAL NOT NULL,
+	set_bytes    INTEGER NOT NULL,
+	get_bytes    INTEGER NOT NULL,
+	config_json  TEXT NOT NULL,
+	tags_json    TEXT NOT NULL,
+	report_json  TEXT NOT NULL
+);
+CREATE TABLE IF NOT EXISTS operations (
+	run_id      INTEGER NOT NULL REFERENCES runs(id),
+	name        TEXT NOT NULL,
+	count       INTEGER NOT NULL,
+	ops_per_sec REAL NOT NULL,
+	min_ms      REAL NOT NULL,
+	avg_ms      REAL NOT NULL,
+	max_ms      REAL NOT NULL,
+	p50_ms      REAL NOT NULL,
+	p95_ms      REAL NOT NULL,
+	p99_ms      REAL NOT NULL,
+	p999_ms     REAL NOT NULL,
+	PRIMARY KEY (run_id, name)
+);`
+
+// appendHistory records a finished run in the SQLite database at path,
+// creating the tables on first use.
+func appendHistory(path string, run *benchmarkRun) error {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	if _, err := db.Exec(historySchema); err != nil {
+		return err
+	}
+
+	report := run.report()
+	configJSON, err := json.Marshal(resolvedConfig(run.ratios))
+	if err != nil {
+		return err
+	}
+	tagsJSON, err := json.Marshal(report.Tags)
+	if err != nil {
+		return err
+	}
+	reportJSON, err := json.Marshal(report)
+	if err != nil {
+		return err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(`INSERT INTO runs
+		(finished_at, addr, clients, keys, duration_sec, set_bytes, get_bytes, config_json, tags_json, report_json)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		time.Now().UTC().Format(time.RFC3339), redisAddr, report.Clients, report.Keys, report.DurationSeconds,
+		report.SetBytes, report.GetBytes, string(configJSON), string(tagsJSON), string(reportJSON))
+	if err != nil {
+		return err
+	}
+	runID, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+
+	for _, op := range report.Operations {
+		if _, err := tx.Exec(`INSERT INTO operations
+			(run_id, name, count, ops_per_sec, min_ms, avg_ms, max_ms, p50_ms, p95_ms, p99_ms, p999_ms)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			runID, op.Name, op.Count, op.OpsPerSec, op.MinMs, op.AvgMs, op.MaxMs,
+			op.P50Ms, op.P95Ms, op.P99Ms, op.P999Ms); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
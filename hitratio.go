@@ -0,0 +1,59 @@
+package main
+
+import (
+	"math"
+	"math/rand"
+	"sync/atomic"
+	"time"
+)
+
+// hitRatioAttempts bounds how many random keys a GET samples while looking
+// for one that currently exists before it gives up and counts as a miss.
+const hitRatioAttempts = 16
+
+// keyTracker records which benchmark keys are expected to exist, so GETs can
+// be steered to hit or miss with -keyspace-hit-ratio. Each key stores the
+// UnixNano time its TTL runs out, math.MaxInt64 for keys without a TTL, or 0
+// once it has been deleted.
+type keyTracker struct {
+	expires []int64
+}
+
+func newKeyTracker(keys int) *keyTracker {
+	return &keyTracker{expires: make([]int64, keys)}
+}
+
+// set marks key i as written with the given TTL.
+func (t *keyTracker) set(i int, ttl time.Duration) {
+	expires := int64(math.MaxInt64)
+	if ttl > 0 {
+		expires = time.Now().Add(ttl).UnixNano()
+	}
+	atomic.StoreInt64(&t.expires[i], expires)
+}
+
+func (t *keyTracker) del(i int) {
+	atomic.StoreInt64(&t.expires[i], 0)
+}
+
+func (t *keyTracker) exists(i int) bool {
+	return atomic.LoadInt64(&t.expires[i]) > time.Now().UnixNano()
+}
+
+// pickExisting returns the index of a random key that is expected to exist.
+// It returns false when none was found within hitRatioAttempts tries, which
+// happens while the keyspace is still mostly empty.
+func (t *keyTracker) pickExisting(rng *rand.Rand) (int, bool) {
+	for i := 0; i < hitRatioAttempts; i++ {
+		n := rng.Intn(len(t.expires))
+		if t.exists(n) {
+			return n, true
+		}
+	}
+	return 0, false
+}
+
+// missKey returns a key under prefix that the benchmark never writes.
+func missKey(rng *rand.Rand, prefix string) string {
+	return prefix + "miss_" + randomString(rng, 8)
+}
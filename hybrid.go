@@ -0,0 +1,170 @@
+package main
+
+import (
+	"context"
+	"math/rand"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// hybridBatchChance converts -hybrid-pipeline-fraction, a fraction of
+// operations, into the chance that a worker iteration sends a pipeline of
+// -hybrid-pipeline-depth operations instead of a single command.
+func hybridBatchChance() float64 {
+	f, d := hybridFraction, float64(hybridDepth)
+	return f / (f + d*(1-f))
+}
+
+// hybridBatch sends depth SET/GET/DEL operations, chosen with the run's
+// ratios, in one pipeline. It serves both -hybrid-pipeline-fraction and
+// -pipeline. The operations count towards the usual totals but their
+// latency is recorded separately, per pipeline and amortized per operation.
+func hybridBatch(ctx context.Context, rng *rand.Rand, workerID int, client benchClient, keys []string, picker *keyPicker, run *benchmarkRun, depth int) {
+	ratios := run.ratios
+	setGetDel := ratios.set + ratios.get + ratios.del
+	if setGetDel == 0 {
+		return
+	}
+
+	pipe := client.Pipeline()
+	setBytes := make([]int64, depth) // Key and value bytes of each SET
+	volatile := make([]bool, depth)
+	for i := 0; i < depth; i++ {
+		key := keys[picker.next()]
+		op := rng.Float64() * setGetDel
+		switch {
+		case op < ratios.set:
+			var value string
+			if run.intValues {
+				value = strconv.FormatInt(rng.Int63n(1e9), 10)
+			} else {
+				value = generateValue(rng, workerID, run.setValueSize(rng))
+			}
+			keyTTL := setTTL(rng)
+			if checksums != nil {
+				checksums.writing(key, []byte(value))
+			}
+			pipe.Set(ctx, key, value, keyTTL)
+			volatile[i] = keyTTL > 0
+			setBytes[i] = int64(len(value)) + int64(len(key))
+		case op < ratios.set+ratios.get:
+			pipe.Get(ctx, key)
+		default:
+			pipe.Del(ctx, key)
+		}
+	}
+
+	start := time.Now()
+	// The error of Exec is only that of the first failed command, so every
+	// command is classified on its own
+	cmds, _ := pipe.Exec(ctx)
+	elapsed := time.Since(start).Seconds() * 1000
+	stats := run.workerStats[workerID-1]
+
+	var sets, gets, dels, volatileSets int
+	var setTotal, getTotal int64
+	for i, cmd := range cmds {
+		err := cmd.Err()
+		failed := err != nil && err != redis.Nil
+		switch cmd.Name() {
+		case "set":
+			if failed {
+				recordError(&stats.set)
+				continue
+			}
+			sets++
+			setTotal += setBytes[i]
+			if volatile[i] {
+				volatileSets++
+			}
+		case "get":
+			if failed {
+				recordError(&stats.get)
+				continue
+			}
+			gets++
+			get := cmd.(*redis.StringCmd)
+			key := get.Args()[1].(string)
+			getTotal += int64(len(get.Val())) + int64(len(key))
+			if checksums != nil && err == nil {
+				checksums.read(key, get.Val())
+			}
+		case "del":
+			if failed {
+				recordError(&stats.del)
+				continue
+			}
+			dels++
+		}
+	}
+
+	// Commands that failed have no latency, but those that succeeded in
+	// the same pipeline keep theirs
+	if completed := sets + gets + dels; completed > 0 {
+		updateStats(&stats.hybridBatch, elapsed)
+		perOp := elapsed / float64(depth)
+		for i := 0; i < completed; i++ {
+			updateStats(&stats.hybridOp, perOp)
+		}
+	}
+
+	counters := &run.counters[workerID-1]
+	atomic.AddInt64(&counters.set, int64(sets))
+	atomic.AddInt64(&counters.get, int64(gets))
+	atomic.AddInt64(&counters.del, int64(dels))
+	atomic.AddInt64(&counters.volatileSets, int64(volatileSets))
+	atomic.AddInt64(&counters.setBytes, setTotal)
+	atomic.AddInt64(&counters.getBytes, getTotal)
+}
+
+// batchSkippedFlags returns the flags set for the run, or for -phase-b,
+// that hybridBatch does not implement. They are rejected together with
+// -pipeline and -hybrid-pipeline-fraction rather than silently ignored.
+func batchSkippedFlags(ratios ...opRatios) []string {
+	var flags []string
+	if deleteStrategy == "expire" {
+		flags = append(flags, "-delete-strategy expire")
+	}
+	if waitAOF {
+		flags = append(flags, "-waitaof")
+	}
+	if refreshTTLOnGet != "" {
+		flags = append(flags, "-refresh-ttl-on-get")
+	}
+	if keyspaceHitRatio >= 0 {
+		flags = append(flags, "-keyspace-hit-ratio")
+	}
+	if oomPause > 0 {
+		flags = append(flags, "-oom-pause")
+	}
+	var other opRatios
+	for _, r := range ratios {
+		other.idletime += r.idletime
+		other.freq += r.freq
+		other.lmpop += r.lmpop
+		other.zmpop += r.zmpop
+		other.incr += r.incr
+		other.hset += r.hset
+		other.lpush += r.lpush
+	}
+	for _, op := range []struct {
+		flag  string
+		ratio float64
+	}{
+		{"-idletime", other.idletime},
+		{"-freq", other.freq},
+		{"-lmpop", other.lmpop},
+		{"-zmpop", other.zmpop},
+		{"-incr", other.incr},
+		{"-hset", other.hset},
+		{"-lpush", other.lpush},
+	} {
+		if op.ratio > 0 {
+			flags = append(flags, op.flag)
+		}
+	}
+	return flags
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"encoding/csv"
+	"io"
+	"strconv"
+	"time"
+)
+
+// intervalCSV writes -csv: one row per second of each run with the SET, GET
+// and DEL operations completed in that second and their average latency.
+// Runs are numbered from 1 in the order they were executed.
+type intervalCSV struct {
+	w    *csv.Writer
+	runs int
+}
+
+func newIntervalCSV(w io.Writer) *intervalCSV {
+	c := &intervalCSV{w: csv.NewWriter(w)}
+	c.w.Write([]string{"run", "elapsed_sec", "set_ops", "get_ops", "del_ops", "set_avg_ms", "get_avg_ms", "del_avg_ms"})
+	c.w.Flush()
+	return c
+}
+
+// latencySum is the number of latency samples and their total in
+// milliseconds, summed over the workers of a run.
+type latencySum struct {
+	count int
+	total float64
+}
+
+func sumLatency(workers []*runStats, op func(*runStats) *operationStats) latencySum {
+	var sum latencySum
+	for _, ws := range workers {
+		s := op(ws)
+		s.mu.Lock()
+		sum.count += s.count
+		sum.total += s.totalTime
+		s.mu.Unlock()
+	}
+	return sum
+}
+
+// avgSince formats the average latency of the samples recorded since prev,
+// or an empty field when there were none, as with -pipeline where only
+// whole pipelines are timed.
+func (s latencySum) avgSince(prev latencySum) string {
+	n := s.count - prev.count
+	if n == 0 {
+		return ""
+	}
+	return strconv.FormatFloat((s.total-prev.total)/float64(n), 'f', 3, 64)
+}
+
+// record appends a row for every second of r until stop is closed. Rows are
+// flushed as they are written, so a killed run still leaves its data.
+func (c *intervalCSV) record(r *benchmarkRun, stop <-chan struct{}) {
+	c.runs++
+	run := strconv.Itoa(c.runs)
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	ops := []func(*runStats) *operationStats{
+		func(s *runStats) *operationStats { return &s.set },
+		func(s *runStats) *operationStats { return &s.get },
+		func(s *runStats) *operationStats { return &s.del },
+	}
+	prevLatency := make([]latencySum, len(ops))
+	var prevSet, prevGet, prevDel int
+	start := time.Now()
+	for {
+		select {
+		case <-stop:
+			return
+		case now := <-ticker.C:
+			set, get, del := setGetDel(r.counters)
+			row := []string{
+				run,
+				strconv.Itoa(int(now.Sub(start).Round(time.Second).Seconds())),
+				strconv.Itoa(set - prevSet),
+				strconv.Itoa(get - prevGet),
+				strconv.Itoa(del - prevDel),
+			}
+			prevSet, prevGet, prevDel = set, get, del
+			for i, op := range ops {
+				cur := sumLatency(r.workerStats, op)
+				row = append(row, cur.avgSince(prevLatency[i]))
+				prevLatency[i] = cur
+			}
+			c.w.Write(row)
+			c.w.Flush()
+			if err := c.w.Error(); err != nil {
+				logger.Error("Failed to write CSV", "error", err)
+			}
+		}
+	}
+}
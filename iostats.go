@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+)
+
+// ioStatFields are the INFO fields compared before and after a run with
+// -io-stats.
+var ioStatFields = []string{
+	"aof_current_size",
+	"aof_delayed_fsync",
+	"sync_full",
+	"total_net_output_bytes",
+	"total_net_repl_output_bytes",
+}
+
+// captureIOStats reads the server-side I/O counters from INFO. Fields the
+// server does not report, such as AOF sizes with appendonly off, are left
+// out.
+func captureIOStats(ctx context.Context, rdb benchClient) (map[string]int64, error) {
+	info, err := rdb.Info(ctx, "all").Result()
+	if err != nil {
+		return nil, err
+	}
+	fields := parseInfo(info)
+	stats := make(map[string]int64)
+	for _, name := range ioStatFields {
+		if v, err := strconv.ParseInt(fields[name], 10, 64); err == nil {
+			stats[name] = v
+		}
+	}
+	return stats, nil
+}
+
+// printIOStats estimates the write amplification of the run from the INFO
+// deltas: bytes appended to the AOF and sent to replicas per SET, and total
+// network output per operation. AOF rewrites during the run shrink the AOF
+// and make the estimate too low.
+func (r *benchmarkRun) printIOStats() {
+	if r.ioErr != nil {
+		fmt.Printf("Server I/O stats unavailable: %v\n", r.ioErr)
+		return
+	}
+	delta := func(name string) (int64, bool) {
+		before, ok1 := r.ioBefore[name]
+		after, ok2 := r.ioAfter[name]
+		return after - before, ok1 && ok2
+	}
+	perOp := func(bytes int64, ops int) float64 {
+		if ops == 0 {
+			return 0
+		}
+		return float64(bytes) / float64(ops)
+	}
+
+	ops := r.totalSet + r.totalGet + r.totalDel
+	fmt.Println("Server I/O (INFO deltas):")
+	if d, ok := delta("aof_current_size"); ok {
+		fmt.Printf("  AOF growth: %d bytes (%.1f bytes per SET)\n", d, perOp(d, r.totalSet))
+	} else {
+		fmt.Println("  AOF growth: n/a (appendonly is off)")
+	}
+	if d, ok := delta("total_net_repl_output_bytes"); ok {
+		fmt.Printf("  Replication output: %d bytes (%.1f bytes per SET)\n", d, perOp(d, r.totalSet))
+	}
+	if d, ok := delta("total_net_output_bytes"); ok {
+		fmt.Printf("  Network output: %d bytes (%.1f bytes per operation)\n", d, perOp(d, ops))
+	}
+	if d, ok := delta("aof_delayed_fsync"); ok {
+		fmt.Printf("  Delayed AOF fsyncs: %d\n", d)
+	}
+	if d, ok := delta("sync_full"); ok {
+		fmt.Printf("  Full replica resyncs: %d\n", d)
+	}
+}
@@ -0,0 +1,50 @@
+package main
+
+import "math/rand"
+
+// Key distributions accepted by -distribution.
+const (
+	distributionUniform = "uniform"
+	distributionZipfian = "zipfian"
+)
+
+// keyPicker selects the index of the next key a worker operates on, from
+// the distribution chosen with -distribution. Under zipfian, the first key
+// of the range is the hottest and popularity falls off with the -zipf-s
+// exponent.
+type keyPicker struct {
+	rng   *rand.Rand
+	first int
+	n     int
+	zipf  *rand.Zipf
+}
+
+// newKeyPicker returns a picker over the n keys starting at index first,
+// drawing from the worker's rng so picks are reproducible with -seed.
+func newKeyPicker(rng *rand.Rand, first, n int) *keyPicker {
+	p := &keyPicker{rng: rng, first: first, n: n}
+	if keyDistribution == distributionZipfian {
+		p.zipf = rand.NewZipf(rng, zipfS, 1, uint64(n-1))
+	}
+	return p
+}
+
+// workerKeyPicker returns the picker for worker workerID of workers: over
+// all keys, or with -partition-keys over the worker's own contiguous
+// range, so no two workers touch the same key.
+func workerKeyPicker(rng *rand.Rand, workerID, workers, numKeys int) *keyPicker {
+	if !partitionKeys {
+		return newKeyPicker(rng, 0, numKeys)
+	}
+	first := (workerID - 1) * numKeys / workers
+	last := workerID * numKeys / workers
+	return newKeyPicker(rng, first, last-first)
+}
+
+// next returns the index of the next key.
+func (p *keyPicker) next() int {
+	if p.zipf != nil {
+		return p.first + int(p.zipf.Uint64())
+	}
+	return p.first + p.rng.Intn(p.n)
+}
@@ -0,0 +1,26 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+)
+
+// logLevel is the minimum level of the diagnostics logger, set by
+// -log-level.
+var logLevel slog.LevelVar
+
+// logger writes diagnostics (progress of the setup, connection failures
+// and recoveries, failed writes of side outputs) to stderr, so that stdout
+// only carries the benchmark results. Invalid flags and failed setup still
+// end the process with a plain error message before a run starts.
+var logger = slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: &logLevel}))
+
+// redisLogger passes the messages go-redis logs itself, such as Sentinel
+// master switches and discarded connections, to logger.
+type redisLogger struct{}
+
+func (redisLogger) Printf(ctx context.Context, format string, v ...interface{}) {
+	logger.Warn(fmt.Sprintf(format, v...), "source", "go-redis")
+}
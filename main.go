@@ -1,138 +1,1346 @@
 package main
 
 import (
+	"bufio"
 	"context"
+	"crypto/tls"
+	"encoding/json"
 	"flag"
 	"fmt"
+	"io"
 	"log"
 	"math"
 	"math/rand"
+	"os"
+	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"text/template"
 	"time"
 
 	"github.com/go-redis/redis/v8"
 )
 
 var (
-	redisAddr                  string
-	redisPass                  string
-	redisDB                    int
-	numClients                 int
-	numKeys                    int
-	keyPrefix                  string
-	ttl                        time.Duration
-	testDuration               time.Duration
-	setRatio                   float64
-	getRatio                   float64
-	delRatio                   float64
-	totalSetData, totalGetData int64
+	redisAddr           string
+	redisUser           string
+	redisPass           string
+	redisPassFile       string
+	redisDB             int
+	dbsSpec             string
+	databases           []int
+	numClients          int
+	poolSize            int
+	minIdleConns        int
+	poolTimeout         time.Duration
+	maxRetries          int
+	retryBackoff        time.Duration
+	numKeys             int
+	keyPrefix           string
+	ttl                 time.Duration
+	ttlJitter           time.Duration
+	testDuration        time.Duration
+	numRequests         int
+	preload             bool
+	cleanup             bool
+	partitionKeys       bool
+	ramp                bool
+	rampStart           int
+	rampStep            int
+	rampMax             int
+	rampStepDuration    time.Duration
+	rampDownDuration    time.Duration
+	targetRate          float64
+	setRatio            float64
+	getRatio            float64
+	delRatio            float64
+	idletimeRatio       float64
+	freqRatio           float64
+	tcpConnectOnly      bool
+	valuePrefix         string
+	valuePrefixMeta     bool
+	waitAOF             bool
+	waitAOFLocal        int
+	waitAOFReplicas     int
+	waitAOFTimeout      time.Duration
+	outlierRemoval      float64
+	isolateOps          bool
+	dedicatedConns      bool
+	reconnectOnError    string
+	reconnectErrors     []string // Parsed from -reconnect-on-error
+	stopAt              string
+	oomPause            time.Duration
+	oomThreshold        int
+	reportTemplate      string
+	refreshTTLOnGet     string
+	progressFormat      string
+	progressFile        string
+	quiet               bool
+	logLevelName        string
+	phaseB              string
+	injectLatency       time.Duration
+	faultRate           float64
+	faultMode           string
+	faultDelay          time.Duration
+	faultSeed           int64
+	faults              *faultHook
+	verifyChecksum      bool
+	checksums           *checksumStore
+	pipelineFile        string
+	pipelineCommands    [][]string
+	commandFlag         string
+	commandTemplate     []string
+	lmpopRatio          float64
+	zmpopRatio          float64
+	incrRatio           float64
+	hsetRatio           float64
+	lpushRatio          float64
+	mpopKeyCount        int
+	mpopPreload         int
+	callbackURL         string
+	callbackTimeout     time.Duration
+	callbackRetries     int
+	warmupAdaptive      bool
+	warmupTolerance     float64
+	warmupMax           time.Duration
+	warmupInterval      time.Duration
+	warmupFixed         time.Duration
+	comparePooling      bool
+	connErrors          *connErrorTracker
+	connErrorsFile      string
+	connErrorsOut       *connErrorsCSV
+	failOnError         float64
+	metricsAddr         string
+	metrics             *benchMetrics
+	csvFile             string
+	csvOut              *intervalCSV
+	keyspaceHitRatio    float64
+	tracker             *keyTracker
+	queueConsumers      int
+	queueTimeout        time.Duration
+	benchMode           string
+	numChannels         int
+	numSubscribers      int
+	scanMatch           string
+	scanType            string
+	scanBaseline        bool
+	scanCount           int64
+	requireSpec         string
+	requirements        []requirement
+	deleteStrategy      string
+	keyDistribution     string
+	zipfS               float64
+	encodingCompare     bool
+	progressAlpha       float64
+	progressWindow      int
+	dumpConfigFile      string
+	compareFile         string
+	regressionThreshold float64
+	configFile          string
+	hybridFraction      float64
+	hybridDepth         int
+	pipelineDepth       int
+	batchSize           int
+	connectionWarmup    bool
+	calibrateOps        int
+	floors              []latencyFloor
+	killInterval        time.Duration
+	churn               *churnMonitor
+	tags                = tagFlags{}
+	valueSize           = valueSizes{def: defaultValueSize}
+	valueSizeMax        int
+	ioStats             bool
+	serverStats         bool
+	ttlFraction         float64
+	hdrLogFile          string
+	hdrLogInterval      time.Duration
+	measureTTFB         bool
+	ttfb                *ttfbStats
+	maxP99              float64
+	slaSpec             string
+	sla                 slaLimits
+	maxErrorRate        float64
+	breachIntervals     int
+	seed                int64
+	spawnBinary         string
+	spawnConfig         string
+	historyDB           string
+	outputFormat        string
+	adaptiveOpMix       bool
+	deterministicMix    bool
+	jitterFreeScheduler bool
+	clusterMode         bool
+	redisNetwork        string
+	sentinelMaster      string
+	tlsEnabled          bool
+	tlsCert             string
+	tlsKey              string
+	tlsCA               string
+	tlsSkipVerify       bool
+	bgsaveAt            time.Duration
+	bgsave              *bgsaveProbe
+	serverStatsMon      *serverStatsMonitor
+	resultsOut          io.Writer
+	progressOut         io.Writer
 )
 
+// opClients holds the client used for each operation type. Without
+// -isolate-ops all three point at the same client and share its pool.
+type opClients struct {
+	set, get, del benchClient
+
+	// Hooks added with addHook, for clients opened later.
+	hooks []redis.Hook
+
+	// The clients of -dbs, or of -dedicated-conns, which the workers use
+	// instead of the ones above.
+	dbs       []opClients
+	dedicated []opClients
+}
+
+// addHook adds h to each distinct client.
+func (c *opClients) addHook(h redis.Hook) {
+	c.hooks = append(c.hooks, h)
+	c.set.AddHook(h)
+	if isolateOps {
+		c.get.AddHook(h)
+		c.del.AddHook(h)
+	}
+	for i := range c.dbs {
+		c.dbs[i].addHook(h)
+	}
+	for i := range c.dedicated {
+		c.dedicated[i].addHook(h)
+	}
+}
+
+// worker returns the clients of worker i (from 0): with -dedicated-conns
+// its own connection, with -dbs those of its database, assigned
+// round-robin.
+func (c *opClients) worker(i int) opClients {
+	switch {
+	case len(c.dedicated) > 0:
+		return c.dedicated[i%len(c.dedicated)]
+	case len(c.dbs) > 0:
+		return c.dbs[i%len(c.dbs)]
+	}
+	return *c
+}
+
 type operationStats struct {
 	minTime   float64
 	maxTime   float64
 	totalTime float64
 	count     int
+	errors    int              // Failed operations, not part of the statistics above
+	hist      latencyHistogram // All latencies, for percentiles and the trimmed mean
+	interval  latencyHistogram // Latencies since the last progress tick, only for -progress-format json-lines
+	metrics   *opMetrics       // Only set with -metrics-addr
 	mu        sync.Mutex
 }
 
+// oomGuard pauses SET operations for a cooldown after the server keeps
+// rejecting writes with OOM errors (maxmemory reached with noeviction).
+type oomGuard struct {
+	consecutive int
+	pausedUntil time.Time
+	pauses      int
+	mu          sync.Mutex
+}
+
+// paused reports whether writes are currently paused.
+func (g *oomGuard) paused() bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return time.Now().Before(g.pausedUntil)
+}
+
+// record updates the guard with the outcome of a SET and starts a pause once
+// -oom-threshold consecutive OOM errors have been seen.
+func (g *oomGuard) record(err error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if err == nil || !strings.HasPrefix(err.Error(), "OOM") {
+		g.consecutive = 0
+		return
+	}
+
+	g.consecutive++
+	if g.consecutive < oomThreshold || time.Now().Before(g.pausedUntil) {
+		return
+	}
+	g.consecutive = 0
+	g.pauses++
+	g.pausedUntil = time.Now().Add(oomPause)
+	logger.Warn("Server is out of memory, pausing writes", "pause", oomPause)
+}
+
+// objectStats tracks OBJECT IDLETIME and OBJECT FREQ operations: the latency
+// of each command and the distribution of the values they return.
+type objectStats struct {
+	idleLatency, freqLatency operationStats
+	idleValues, freqValues   operationStats
+}
+
 func init() {
-	flag.StringVar(&redisAddr, "addr", "localhost:6379", "Redis server address")
-	flag.StringVar(&redisPass, "pass", "", "Redis password")
+	flag.StringVar(&redisAddr, "addr", "localhost:6379", "Redis server address, or comma-separated seed nodes with -cluster")
+	flag.DurationVar(&bgsaveAt, "bgsave-at", 0, "Trigger BGSAVE this far into each run and compare latency during the save with steady state (0 disables)")
+	flag.BoolVar(&tlsEnabled, "tls", false, "Connect to Redis over TLS")
+	flag.StringVar(&tlsCert, "tls-cert", "", "Client certificate file for -tls (requires -tls-key)")
+	flag.StringVar(&tlsKey, "tls-key", "", "Client private key file for -tls (requires -tls-cert)")
+	flag.StringVar(&tlsCA, "tls-ca", "", "CA bundle to verify the server certificate with -tls (default: system roots)")
+	flag.BoolVar(&tlsSkipVerify, "tls-skip-verify", false, "Skip server certificate verification with -tls, for self-signed endpoints")
+	flag.BoolVar(&ramp, "ramp", false, "Run phases with a growing number of clients and report throughput and p99 per client count")
+	flag.IntVar(&rampStart, "ramp-start", 1, "Clients in the first -ramp phase")
+	flag.IntVar(&rampStep, "ramp-step", 1, "Clients added in each further -ramp phase")
+	flag.IntVar(&rampMax, "ramp-max", 0, "Clients in the last -ramp phase (0 uses -clients)")
+	flag.DurationVar(&rampStepDuration, "ramp-step-duration", 5*time.Second, "Duration of each -ramp phase")
+	flag.BoolVar(&partitionKeys, "partition-keys", false, "Give each client a disjoint contiguous range of the keys, so clients never touch each other's keys")
+	flag.BoolVar(&cleanup, "cleanup", false, "Remove every benchmark key (UNLINK, or DEL before Redis 4.0) after the run instead of leaving them to expire")
+	flag.BoolVar(&preload, "preload", false, "Write every key once before the run, so GET and DEL act on existing data from the first operation")
+	flag.BoolVar(&jitterFreeScheduler, "jitter-free-scheduler", false, "Pace -rate by absolute dispatch times from the run start instead of a token bucket, so late operations do not shift the later ones and the rate does not drift")
+	flag.DurationVar(&rampDownDuration, "ramp-down", 0, "After the measured window, keep offering load for this long while lowering -rate linearly to zero, outside the statistics (0 stops at once)")
+	flag.Float64Var(&targetRate, "rate", 0, "Target operations per second across all clients; workers pace themselves to hold it (0 runs unbounded)")
+	flag.StringVar(&redisNetwork, "network", "tcp", "Network to connect over: tcp, or unix with -addr set to the socket path")
+	flag.StringVar(&sentinelMaster, "sentinel-master", "", "Connect through Redis Sentinel to the master with this name; -addr lists the sentinels, comma-separated")
+	flag.BoolVar(&clusterMode, "cluster", false, "Connect to a Redis Cluster, following MOVED/ASK redirections")
+	flag.StringVar(&redisUser, "user", "", "Redis 6+ ACL username to authenticate as with the password (empty uses the default user)")
+	flag.StringVar(&redisPass, "pass", "", "Redis password (prefer -pass-file or REDIS_PASSWORD)")
+	flag.StringVar(&redisPassFile, "pass-file", "", "File containing the Redis password")
 	flag.IntVar(&redisDB, "db", 0, "Redis database number")
-	flag.IntVar(&numClients, "clients", 10, "Number of concurrent clients")
+	flag.StringVar(&dbsSpec, "dbs", "", "Spread workers round-robin across databases: a count (4 uses 0-3) or a comma-separated list such as 0,5,9")
+	flag.IntVar(&numClients, "clients", 10, "Number of concurrent clients (with -rate and no -clients, derived from the rate and the measured latency)")
+	flag.IntVar(&poolSize, "pool-size", 0, "Maximum connections in the client pool (0 uses one per client)")
+	flag.IntVar(&minIdleConns, "min-idle-conns", 0, "Idle connections the client pool keeps open")
+	flag.DurationVar(&poolTimeout, "pool-timeout", 0, "How long an operation waits for a free pool connection (0 uses the go-redis default of 4s)")
+	flag.IntVar(&maxRetries, "max-retries", 0, "Times go-redis retries a command after a network error (0 uses the go-redis default of 3, -1 disables retries)")
+	flag.DurationVar(&retryBackoff, "retry-backoff", 0, "Minimum backoff between retries, doubling up to 64 times this (0 uses the go-redis defaults of 8ms to 512ms)")
 	flag.IntVar(&numKeys, "keys", 1000, "Number of keys to test")
 	flag.StringVar(&keyPrefix, "prefix", "benchmark_", "Key prefix")
-	flag.DurationVar(&ttl, "ttl", 60*time.Second, "Key TTL")
+	flag.DurationVar(&ttl, "ttl", 60*time.Second, "Key TTL (0 writes persistent keys)")
+	flag.DurationVar(&ttlJitter, "ttl-jitter", 0, "Spread each SET's TTL uniformly over -ttl ± this much, so keys do not expire in step")
+	flag.Float64Var(&ttlFraction, "ttl-fraction", 1, "Fraction of SETs that get -ttl; the rest write persistent keys")
 	flag.DurationVar(&testDuration, "duration", 10*time.Second, "Test duration")
+	flag.IntVar(&numRequests, "requests", 0, "Issue exactly this many operations per run instead of running for -duration (0 disables)")
+	flag.DurationVar(&warmupFixed, "warmup", 0, "Run the workload for this long without recording before measurement starts (0 disables)")
+	flag.BoolVar(&warmupAdaptive, "warmup-adaptive", false, "Warm up without recording until throughput stabilizes, then start measuring")
+	flag.Float64Var(&warmupTolerance, "warmup-tolerance", 0.05, "Maximum relative throughput change between warmup intervals that counts as stable")
+	flag.DurationVar(&warmupMax, "warmup-max", 30*time.Second, "Upper bound on the adaptive warmup")
+	flag.DurationVar(&warmupInterval, "warmup-interval", time.Second, "Length of the intervals compared during adaptive warmup")
+	flag.BoolVar(&deterministicMix, "deterministic-mix", false, "Select operations from a fixed weighted round-robin cycle instead of at random, so the realized mix matches the ratios in any window (keys stay random)")
+	flag.BoolVar(&adaptiveOpMix, "adaptive-mix", false, "Continuously adjust SET/GET/DEL selection so the completed operation counts converge to the configured ratios")
+	flag.StringVar(&outputFormat, "output", "text", "Summary format: text, or json to print one JSON object per run to stdout with all other output on stderr")
+	flag.StringVar(&historyDB, "history-db", "", "Append each run's configuration, tags and results to this SQLite database file")
+	flag.StringVar(&spawnBinary, "spawn-server", "", "Start this redis-server binary on the port of -addr, benchmark it and shut it down on exit")
+	flag.StringVar(&spawnConfig, "spawn-config", "", "Config file passed to the -spawn-server binary")
+	flag.Int64Var(&seed, "seed", 0, "Base seed of the per-worker random generators, for reproducible key and operation sequences (0 picks a time-based seed)")
+	flag.StringVar(&slaSpec, "sla-p99", "", "Exit with status 6 when the p99 latency of an operation over the run exceeds this many milliseconds: a limit for all operations such as 5, per operation such as SET=2,GET=1.5, or both")
+	flag.Float64Var(&maxP99, "max-p99", 0, "Abort with exit status 3 when the p99 latency of all commands exceeds this many milliseconds for -breach-intervals seconds in a row (0 disables)")
+	flag.Float64Var(&maxErrorRate, "max-error-rate", 0, "Abort with exit status 3 when the fraction of failed commands exceeds this for -breach-intervals seconds in a row (0 disables)")
+	flag.IntVar(&breachIntervals, "breach-intervals", 3, "Consecutive one-second intervals a -max-p99 or -max-error-rate breach must last before the run aborts")
+	flag.BoolVar(&measureTTFB, "ttfb", false, "Measure GET time to first byte on the connection and split GET latency into first byte and transfer time")
+	flag.StringVar(&hdrLogFile, "hdr-log", "", "Write per-command latency histograms to this file in the HdrHistogram interval log format")
+	flag.DurationVar(&hdrLogInterval, "hdr-log-interval", time.Second, "Interval between histograms written to -hdr-log")
+	flag.BoolVar(&serverStats, "server-stats", false, "Sample INFO every second and report the change in used memory, evicted keys and keyspace hits/misses over each run")
+	flag.BoolVar(&ioStats, "io-stats", false, "Estimate write amplification from INFO deltas: AOF and replication bytes per SET and network output per operation")
+	flag.IntVar(&valueSizeMax, "value-size-max", 0, "When set, each SET writes a value of a uniformly random size between the -value-size for set and this many bytes")
+	flag.Var(&valueSize, "value-size", "Value size in bytes, with optional per-operation overrides, e.g. 100,set=256,lpush=16 (operations: "+strings.Join(valueSizeOps, ", ")+")")
+	flag.Var(tags, "tag", "Attach key=value metadata to the JSON results; repeat for several tags")
+	flag.DurationVar(&killInterval, "kill-interval", 0, "Kill one of the benchmark's own connections with CLIENT KILL at this interval and report reconnections and errors (0 disables)")
+	flag.IntVar(&calibrateOps, "calibrate", 0, "Measure the unloaded latency floor with this many sequential SET/GET/DEL commands each before the run (0 disables)")
+	flag.BoolVar(&connectionWarmup, "connection-warmup", false, "Open every pooled connection before the measured run so it does not pay for connection setup")
+	flag.Float64Var(&hybridFraction, "hybrid-pipeline-fraction", 0, "Fraction of SET/GET/DEL operations sent in pipelines instead of individually (0 disables)")
+	flag.IntVar(&batchSize, "batch", 1, "Send SET and GET operations as MSET and MGET of this many keys each (1 sends single-key commands)")
+	flag.IntVar(&pipelineDepth, "pipeline", 1, "Send SET/GET/DEL operations in pipelines of this many commands, mixed by the ratios (1 sends one command at a time)")
+	flag.IntVar(&hybridDepth, "hybrid-pipeline-depth", 10, "Operations per pipeline for -hybrid-pipeline-fraction")
+	flag.StringVar(&compareFile, "compare", "", "Compare the run with this baseline JSON report and exit with status 5 if it regressed beyond -regression-threshold")
+	flag.Float64Var(&regressionThreshold, "regression-threshold", 5, "Percentage by which ops/sec may drop, or p50/p99 latency grow, against the -compare baseline")
+	flag.StringVar(&configFile, "config", "", "Load flag values from this YAML or JSON file; flags given on the command line take precedence")
+	flag.StringVar(&dumpConfigFile, "dump-config", "", "Write the resolved configuration as JSON to this file before the run starts (the password is omitted)")
+	flag.Float64Var(&progressAlpha, "progress-ema-alpha", 0.3, "Smoothing factor (0-1] of the exponential moving average applied to live ops/sec and latency; 1 shows raw per-second values")
+	flag.IntVar(&progressWindow, "progress-window", 10, "Seconds of the rolling average of ops/sec shown by the live progress")
+	flag.BoolVar(&encodingCompare, "encoding-compare", false, "Run the workload three times with values that Redis stores as int, embstr and raw strings, then compare the phases")
+	flag.StringVar(&keyDistribution, "distribution", distributionUniform, "Key access distribution: uniform or zipfian (a few hot keys get most operations)")
+	flag.Float64Var(&zipfS, "zipf-s", 1.1, "Skew exponent of the zipfian distribution, greater than 1 (higher is more skewed)")
+	flag.StringVar(&deleteStrategy, "delete-strategy", "del", "How -del operations remove keys: del (explicit DEL) or expire (EXPIRE with a 1s TTL, left to Redis expiry)")
+	flag.StringVar(&requireSpec, "require", "", "Comma-separated server preconditions checked before starting, e.g. version>=7.0,module=search,maxmemory-policy=allkeys-lru,info:role=master")
+	flag.StringVar(&benchMode, "mode", modeKV, "Workload: kv (key-value operations), pubsub (PUBLISH to channels that the other workers subscribe to) or scan (repeated full SCAN iterations of the keyspace)")
+	flag.IntVar(&numChannels, "channels", 1, "Channels for -mode pubsub")
+	flag.IntVar(&numSubscribers, "subscribers", 0, "Workers that subscribe in -mode pubsub while the rest publish (0 uses half of -clients)")
+	flag.StringVar(&scanMatch, "scan-match", "", "MATCH pattern of -mode scan (empty scans every key)")
+	flag.BoolVar(&scanBaseline, "scan-baseline", true, "With -scan-match or -scan-type, follow the -mode scan run with an unfiltered one and compare them")
+	flag.StringVar(&scanType, "scan-type", "", "TYPE filter of -mode scan, such as string or hash (Redis 6.0+, empty returns every type)")
+	flag.Int64Var(&scanCount, "scan-count", 0, "COUNT hint of -mode scan (0 uses the server default of 10)")
+	flag.IntVar(&queueConsumers, "queue-consumers", 0, "Run a producer/consumer queue: this many workers BLPOP while the rest LPUSH (0 disables)")
+	flag.DurationVar(&queueTimeout, "queue-timeout", time.Second, "BLPOP timeout for -queue-consumers, in whole seconds")
+	flag.Float64Var(&keyspaceHitRatio, "keyspace-hit-ratio", -1, "Target fraction of GETs that hit an existing key, between 0 and 1 (negative disables)")
+	flag.StringVar(&stopAt, "stop-at", "", "Stop at this RFC3339 wall-clock time instead of after -duration")
 	flag.Float64Var(&setRatio, "set", 0.5, "Proportion of SET operations")
 	flag.Float64Var(&getRatio, "get", 0.4, "Proportion of GET operations")
 	flag.Float64Var(&delRatio, "del", 0.1, "Proportion of DEL operations")
+	flag.StringVar(&refreshTTLOnGet, "refresh-ttl-on-get", "", "Reset the TTL on every GET: getex (single GETEX) or expire (GET followed by EXPIRE)")
+	flag.Float64Var(&incrRatio, "incr", 0, "Proportion of INCR operations on counter keys")
+	flag.Float64Var(&hsetRatio, "hset", 0, "Proportion of HSET operations on hash keys")
+	flag.Float64Var(&lpushRatio, "lpush", 0, "Proportion of LPUSH operations on list keys (the lists grow for the whole run)")
+	flag.Float64Var(&idletimeRatio, "idletime", 0, "Proportion of OBJECT IDLETIME operations (requires a non-LFU maxmemory-policy)")
+	flag.Float64Var(&lmpopRatio, "lmpop", 0, "Proportion of LMPOP operations across -mpop-keys lists (Redis 7.0+)")
+	flag.Float64Var(&zmpopRatio, "zmpop", 0, "Proportion of ZMPOP operations across -mpop-keys sorted sets (Redis 7.0+)")
+	flag.IntVar(&mpopKeyCount, "mpop-keys", 4, "Number of list/sorted set keys used by LMPOP and ZMPOP")
+	flag.IntVar(&mpopPreload, "mpop-preload", 1000, "Elements pushed into each LMPOP/ZMPOP key before the run")
+	flag.Float64Var(&freqRatio, "freq", 0, "Proportion of OBJECT FREQ operations (requires an LFU maxmemory-policy)")
+	flag.StringVar(&csvFile, "csv", "", "Write one row per second of each run with the SET/GET/DEL operations and average latency of that second to this CSV file")
+	flag.StringVar(&metricsAddr, "metrics-addr", "", "Serve live Prometheus metrics on /metrics at this address, e.g. :9090 (tags become labels)")
+	flag.Float64Var(&failOnError, "fail-on-error", -1, "Exit with status 4 when the share of failed operations of a run exceeds this fraction, e.g. 0.01 (0 fails on any error, negative disables)")
+	flag.StringVar(&connErrorsFile, "conn-errors-csv", "", "Write the per-second timeline of dial errors, pool timeouts and command errors of each run to this CSV file")
+	flag.BoolVar(&comparePooling, "compare-pooling", false, "Run the workload twice, with a shared connection pool and with a dedicated connection per worker, and compare them")
+	flag.StringVar(&phaseB, "phase-b", "", "Run a second phase with these ratio overrides and SET value size (e.g. set=0.1,get=0.9,value-size=1024) and compare it to the first")
+	flag.StringVar(&progressFormat, "progress-format", "ansi", "Live progress format: ansi (redrawn per-client counters) or json-lines")
+	flag.StringVar(&logLevelName, "log-level", "info", "Minimum level of the diagnostics written to stderr: debug, info, warn or error")
+	flag.BoolVar(&quiet, "quiet", false, "Disable the live progress display; the final summary is still printed")
+	flag.StringVar(&progressFile, "progress-file", "", "Write json-lines progress to this file instead of stdout")
+	flag.StringVar(&reportTemplate, "report-template", "", "Render the summary with a text/template file, or a built-in template: markdown, html")
+	flag.DurationVar(&injectLatency, "inject-latency", 0, "Artificial delay added to every request round trip to simulate a distant server")
+	flag.Float64Var(&faultRate, "fault-rate", 0, "Fraction of commands (0-1) hit by client-side fault injection")
+	flag.StringVar(&faultMode, "fault-mode", "fail", "Injected fault: fail, delay or mixed")
+	flag.DurationVar(&faultDelay, "fault-delay", 100*time.Millisecond, "Delay applied by delay faults")
+	flag.Int64Var(&faultSeed, "fault-seed", 1, "Seed for choosing which commands are faulted")
+	flag.BoolVar(&verifyChecksum, "verify-checksum", false, "Verify GET results against a CRC32 of the last value written to each key")
+	flag.StringVar(&commandFlag, "command", "", "Benchmark this command instead of the SET/GET/DEL mix, e.g. \"SETEX {key} 30 {value}\"; {key}, {value} and {rand} are filled in per call")
+	flag.StringVar(&pipelineFile, "command-pipeline-file", "", "Repeatedly execute the commands in this file as one pipeline instead of the SET/GET/DEL mix")
+	flag.StringVar(&callbackURL, "results-callback-url", "", "POST the JSON results to this URL when the run completes")
+	flag.DurationVar(&callbackTimeout, "results-callback-timeout", 10*time.Second, "Timeout for each -results-callback-url request")
+	flag.IntVar(&callbackRetries, "results-callback-retries", 3, "Retries for a failed -results-callback-url request")
+	flag.BoolVar(&tcpConnectOnly, "tcp-connect-only", false, "Only probe TCP connect latency to -addr, without the Redis protocol")
+	flag.StringVar(&valuePrefix, "value-prefix", "", "Marker prepended to every generated value")
+	flag.BoolVar(&valuePrefixMeta, "value-prefix-meta", false, "Append worker id and timestamp to -value-prefix")
+	flag.BoolVar(&waitAOF, "waitaof", false, "Issue WAITAOF after every successful SET (Redis 7.2+)")
+	flag.IntVar(&waitAOFLocal, "waitaof-local", 1, "WAITAOF numlocal: require fsync on the local AOF")
+	flag.IntVar(&waitAOFReplicas, "waitaof-replicas", 0, "WAITAOF numreplicas: number of replicas that must fsync")
+	flag.DurationVar(&waitAOFTimeout, "waitaof-timeout", time.Second, "WAITAOF timeout")
+	flag.DurationVar(&oomPause, "oom-pause", 0, "Pause SET operations for this long after repeated OOM errors (0 disables)")
+	flag.IntVar(&oomThreshold, "oom-threshold", 10, "Consecutive OOM errors that trigger an -oom-pause")
+	flag.BoolVar(&dedicatedConns, "dedicated-conns", false, "Give every worker its own connection instead of sharing the client pool")
+	flag.StringVar(&reconnectOnError, "reconnect-on-error", "", "With dedicated connections, close a worker's connection and open a new one after a command fails with one of these comma-separated error prefixes, such as READONLY,LOADING, or after any failure with all (default reuses it)")
+	flag.BoolVar(&isolateOps, "isolate-ops", false, "Give SET, GET and DEL separate clients with their own connection pools")
+	flag.Float64Var(&outlierRemoval, "latency-outlier-removal", 0, "Also report a trimmed mean dropping this percentage of the fastest and slowest samples")
 }
 
 func main() {
+	if len(os.Args) > 1 {
+		if cmd, ok := subcommands[os.Args[1]]; ok {
+			cmd(os.Args[2:])
+			return
+		}
+		if os.Args[1] == "run" {
+			os.Args = append(os.Args[:1], os.Args[2:]...)
+		}
+	}
+	flag.Usage = usage
 	flag.Parse()
+	if err := run(); err != nil {
+		log.Fatal(err)
+	}
+	exitOnSLA()
+	exitOnRegression()
+	exitOnErrorRate()
+}
+
+// run runs the benchmark configured by the flags. Errors are returned
+// rather than fatal, so the deferred cleanup, such as stopping a
+// -spawn-server process, runs on every path.
+func run() error {
+
+	if configFile != "" {
+		if err := loadConfig(configFile); err != nil {
+			return fmt.Errorf("Failed to load -config file: %v", err)
+		}
+	}
+	if err := logLevel.UnmarshalText([]byte(logLevelName)); err != nil {
+		return fmt.Errorf("Invalid -log-level %q: expected debug, info, warn or error", logLevelName)
+	}
+	redis.SetLogger(redisLogger{})
+
+	if outlierRemoval < 0 || outlierRemoval >= 50 {
+		return fmt.Errorf("-latency-outlier-removal must be in [0, 50), got %v", outlierRemoval)
+	}
+
+	password, err := resolvePassword()
+	if err != nil {
+		return fmt.Errorf("Failed to read Redis password: %v", err)
+	}
+	redisPass = password
+
+	switch refreshTTLOnGet {
+	case "", "getex", "expire":
+	default:
+		return fmt.Errorf("Invalid -refresh-ttl-on-get %q: expected getex or expire", refreshTTLOnGet)
+	}
+
+	switch outputFormat {
+	case "text":
+	case "json":
+		// Keep stdout valid JSON: everything else printed goes to stderr
+		resultsOut = os.Stdout
+		os.Stdout = os.Stderr
+	default:
+		return fmt.Errorf("Invalid -output %q: expected text or json", outputFormat)
+	}
 
-	// Normalize operation ratios
-	totalRatio := setRatio + getRatio + delRatio
-	setRatio /= totalRatio
-	getRatio /= totalRatio
-	delRatio /= totalRatio
+	switch progressFormat {
+	case "ansi", "json-lines":
+	default:
+		return fmt.Errorf("Invalid -progress-format %q: expected ansi or json-lines", progressFormat)
+	}
+
+	if warmupFixed < 0 {
+		return fmt.Errorf("-warmup must not be negative, got %v", warmupFixed)
+	}
+	if warmupFixed > 0 && warmupAdaptive {
+		return fmt.Errorf("-warmup and -warmup-adaptive are mutually exclusive")
+	}
+	if warmupAdaptive && (warmupInterval <= 0 || warmupTolerance <= 0) {
+		return fmt.Errorf("-warmup-interval and -warmup-tolerance must be positive")
+	}
+
+	if slaSpec != "" {
+		limits, err := parseSLA(slaSpec)
+		if err != nil {
+			return fmt.Errorf("Invalid -sla-p99: %v", err)
+		}
+		sla = limits
+	}
+	if maxP99 < 0 || maxErrorRate < 0 || maxErrorRate > 1 {
+		return fmt.Errorf("-max-p99 must not be negative and -max-error-rate must be between 0 and 1")
+	}
+	if breachIntervals < 1 {
+		return fmt.Errorf("-breach-intervals must be at least 1, got %d", breachIntervals)
+	}
 
-	rdb := redis.NewClient(&redis.Options{
+	if hdrLogFile != "" && hdrLogInterval <= 0 {
+		return fmt.Errorf("-hdr-log-interval must be positive, got %v", hdrLogInterval)
+	}
+
+	if ttl < 0 || ttlJitter < 0 {
+		return fmt.Errorf("-ttl and -ttl-jitter must not be negative")
+	}
+	if ttlJitter > 0 && ttlJitter >= ttl {
+		return fmt.Errorf("-ttl-jitter %v must be less than -ttl %v", ttlJitter, ttl)
+	}
+	if ttl == 0 && refreshTTLOnGet != "" {
+		return fmt.Errorf("-refresh-ttl-on-get needs a -ttl to refresh")
+	}
+	if ttlFraction < 0 || ttlFraction > 1 {
+		return fmt.Errorf("-ttl-fraction must be between 0 and 1, got %v", ttlFraction)
+	}
+
+	if killInterval < 0 {
+		return fmt.Errorf("-kill-interval must not be negative, got %v", killInterval)
+	}
+
+	if hybridFraction < 0 || hybridFraction > 1 {
+		return fmt.Errorf("-hybrid-pipeline-fraction must be between 0 and 1, got %v", hybridFraction)
+	}
+	if hybridFraction > 0 && hybridDepth < 1 {
+		return fmt.Errorf("-hybrid-pipeline-depth must be at least 1, got %d", hybridDepth)
+	}
+
+	if progressAlpha <= 0 || progressAlpha > 1 {
+		return fmt.Errorf("-progress-ema-alpha must be in (0, 1], got %v", progressAlpha)
+	}
+	if progressWindow < 1 {
+		return fmt.Errorf("-progress-window must be at least 1, got %d", progressWindow)
+	}
+
+	if encodingCompare && (phaseB != "" || pipelineFile != "" || queueConsumers > 0 || valuePrefix != "" || preload) {
+		return fmt.Errorf("-encoding-compare cannot be combined with -phase-b, -command-pipeline-file, -queue-consumers, -value-prefix or -preload")
+	}
+	if valueSizeMax != 0 {
+		if valueSizeMax < valueSize.get("set") {
+			return fmt.Errorf("-value-size-max must be at least the SET value size %d, got %d", valueSize.get("set"), valueSizeMax)
+		}
+		if encodingCompare {
+			return fmt.Errorf("-value-size-max cannot be combined with -encoding-compare")
+		}
+	}
+
+	if pipelineDepth < 1 {
+		return fmt.Errorf("-pipeline must be at least 1, got %d", pipelineDepth)
+	}
+	if batchSize < 1 {
+		return fmt.Errorf("-batch must be at least 1, got %d", batchSize)
+	}
+	if batchSize > 1 && (pipelineDepth > 1 || clusterMode || keyspaceHitRatio >= 0 || refreshTTLOnGet != "" || waitAOF) {
+		return fmt.Errorf("-batch cannot be combined with -pipeline, -cluster, -keyspace-hit-ratio, -refresh-ttl-on-get or -waitaof")
+	}
+	if pipelineDepth > 1 && (pipelineFile != "" || queueConsumers > 0 || hybridFraction > 0 || adaptiveOpMix) {
+		return fmt.Errorf("-pipeline cannot be combined with -command-pipeline-file, -queue-consumers, -hybrid-pipeline-fraction or -adaptive-mix")
+	}
+	if deterministicMix && (adaptiveOpMix || pipelineDepth > 1 || hybridFraction > 0) {
+		return fmt.Errorf("-deterministic-mix cannot be combined with -adaptive-mix, -pipeline or -hybrid-pipeline-fraction")
+	}
+	if adaptiveOpMix && (pipelineFile != "" || queueConsumers > 0 || hybridFraction > 0) {
+		return fmt.Errorf("-adaptive-mix cannot be combined with -command-pipeline-file, -queue-consumers or -hybrid-pipeline-fraction")
+	}
+
+	if dedicatedConns && (isolateOps || comparePooling || dbsSpec != "" || benchMode == modePubSub) {
+		return fmt.Errorf("-dedicated-conns cannot be combined with -isolate-ops, -compare-pooling, -dbs or -mode pubsub")
+	}
+	if reconnectOnError != "" {
+		if !dedicatedConns && !comparePooling {
+			return fmt.Errorf("-reconnect-on-error requires -dedicated-conns or -compare-pooling")
+		}
+		prefixes, err := parseReconnectErrors(reconnectOnError)
+		if err != nil {
+			return fmt.Errorf("Invalid -reconnect-on-error %q: %v", reconnectOnError, err)
+		}
+		reconnectErrors = prefixes
+	}
+	if dbsSpec != "" {
+		dbs, err := parseDatabases(dbsSpec)
+		if err != nil {
+			return fmt.Errorf("Invalid -dbs %q: %v", dbsSpec, err)
+		}
+		if redisDB != 0 || clusterMode || isolateOps || comparePooling || keyspaceHitRatio >= 0 || verifyChecksum || benchMode == modePubSub {
+			return fmt.Errorf("-dbs cannot be combined with -db, -cluster, -isolate-ops, -compare-pooling, -keyspace-hit-ratio, -verify-checksum or -mode pubsub")
+		}
+		databases = dbs
+	}
+	if clusterMode && (redisDB != 0 || spawnBinary != "" || killInterval > 0 || tcpConnectOnly || bgsaveAt > 0 || serverStats) {
+		return fmt.Errorf("-cluster cannot be combined with -db, -spawn-server, -kill-interval, -tcp-connect-only, -bgsave-at or -server-stats")
+	}
+	switch redisNetwork {
+	case "tcp":
+	case "unix":
+		if clusterMode || sentinelMaster != "" || spawnBinary != "" {
+			return fmt.Errorf("-network unix cannot be combined with -cluster, -sentinel-master or -spawn-server")
+		}
+		fi, err := os.Stat(redisAddr)
+		if err != nil {
+			return fmt.Errorf("Unix socket %q not found: %v (set -addr to the unixsocket path from redis.conf)", redisAddr, err)
+		}
+		if fi.Mode()&os.ModeSocket == 0 {
+			return fmt.Errorf("-addr %q is not a Unix socket", redisAddr)
+		}
+	default:
+		return fmt.Errorf("Invalid -network %q: expected tcp or unix", redisNetwork)
+	}
+	if sentinelMaster != "" && (clusterMode || spawnBinary != "" || tcpConnectOnly) {
+		return fmt.Errorf("-sentinel-master cannot be combined with -cluster, -spawn-server or -tcp-connect-only")
+	}
+
+	if ramp {
+		if rampMax == 0 {
+			rampMax = numClients
+		}
+		if rampStart < 1 || rampStep < 1 || rampMax < rampStart || rampStepDuration <= 0 {
+			return fmt.Errorf("-ramp needs -ramp-start and -ramp-step of at least 1, -ramp-max of at least -ramp-start and a positive -ramp-step-duration")
+		}
+		if phaseB != "" || encodingCompare || comparePooling || numRequests > 0 || stopAt != "" || pipelineFile != "" || queueConsumers > 0 {
+			return fmt.Errorf("-ramp cannot be combined with -phase-b, -encoding-compare, -compare-pooling, -requests, -stop-at, -command-pipeline-file or -queue-consumers")
+		}
+	}
+	if partitionKeys {
+		if maxClients := max(numClients, rampMax); numKeys < maxClients {
+			return fmt.Errorf("-partition-keys needs at least one key per client: -keys %d is less than %d clients", numKeys, maxClients)
+		}
+		if keyspaceHitRatio >= 0 || queueConsumers > 0 {
+			return fmt.Errorf("-partition-keys cannot be combined with -keyspace-hit-ratio or -queue-consumers")
+		}
+	}
+	if poolSize < 0 || minIdleConns < 0 || poolTimeout < 0 {
+		return fmt.Errorf("-pool-size, -min-idle-conns and -pool-timeout must not be negative")
+	}
+	if poolSize == 0 {
+		poolSize = max(numClients, rampMax)
+	}
+	if maxRetries < -1 || retryBackoff < 0 {
+		return fmt.Errorf("-max-retries must be -1 or more and -retry-backoff must not be negative")
+	}
+	if minIdleConns > poolSize {
+		return fmt.Errorf("-min-idle-conns %d exceeds -pool-size %d", minIdleConns, poolSize)
+	}
+	if targetRate < 0 {
+		return fmt.Errorf("-rate must not be negative, got %v", targetRate)
+	}
+	if numRequests < 0 {
+		return fmt.Errorf("-requests must not be negative, got %d", numRequests)
+	}
+	if jitterFreeScheduler && targetRate == 0 {
+		return fmt.Errorf("-jitter-free-scheduler needs a -rate to pace")
+	}
+	if rampDownDuration < 0 {
+		return fmt.Errorf("-ramp-down must not be negative, got %v", rampDownDuration)
+	}
+	if rampDownDuration > 0 {
+		if targetRate == 0 {
+			return fmt.Errorf("-ramp-down needs a -rate to lower")
+		}
+		if ramp || phaseB != "" || encodingCompare || comparePooling || numRequests > 0 || stopAt != "" || queueConsumers > 0 || pipelineFile != "" {
+			return fmt.Errorf("-ramp-down cannot be combined with -ramp, -phase-b, -encoding-compare, -compare-pooling, -requests, -stop-at, -queue-consumers or -command-pipeline-file")
+		}
+	}
+	if numRequests > 0 && (stopAt != "" || tcpConnectOnly) {
+		return fmt.Errorf("-requests cannot be combined with -stop-at or -tcp-connect-only")
+	}
+	if bgsaveAt < 0 || bgsaveAt > 0 && numRequests == 0 && bgsaveAt >= testDuration {
+		return fmt.Errorf("-bgsave-at must be between 0 and -duration %v, got %v", testDuration, bgsaveAt)
+	}
+
+	var tlsConfig *tls.Config
+	if tlsEnabled {
+		cfg, err := buildTLSConfig()
+		if err != nil {
+			return fmt.Errorf("Invalid TLS configuration: %v", err)
+		}
+		tlsConfig = cfg
+	} else if tlsCert != "" || tlsKey != "" || tlsCA != "" || tlsSkipVerify {
+		return fmt.Errorf("-tls-cert, -tls-key, -tls-ca and -tls-skip-verify require -tls")
+	}
+
+	if comparePooling && (phaseB != "" || encodingCompare || isolateOps) {
+		return fmt.Errorf("-compare-pooling cannot be combined with -phase-b, -encoding-compare or -isolate-ops")
+	}
+
+	switch deleteStrategy {
+	case "del", "expire":
+	default:
+		return fmt.Errorf("Invalid -delete-strategy %q: expected del or expire", deleteStrategy)
+	}
+	switch keyDistribution {
+	case distributionUniform:
+	case distributionZipfian:
+		if zipfS <= 1 {
+			return fmt.Errorf("-zipf-s must be greater than 1, got %v", zipfS)
+		}
+	default:
+		return fmt.Errorf("Invalid -distribution %q: expected uniform or zipfian", keyDistribution)
+	}
+
+	if requireSpec != "" {
+		reqs, err := parseRequirements(requireSpec)
+		if err != nil {
+			return fmt.Errorf("Invalid -require: %v", err)
+		}
+		requirements = reqs
+	}
+
+	switch benchMode {
+	case modeKV:
+	case modePubSub:
+		if numSubscribers == 0 {
+			numSubscribers = numClients / 2
+		}
+		if numSubscribers < 1 || numSubscribers >= numClients {
+			return fmt.Errorf("-mode pubsub needs at least one subscriber and one publisher among the %d clients, got -subscribers %d", numClients, numSubscribers)
+		}
+		if numChannels < 1 {
+			return fmt.Errorf("-channels must be at least 1, got %d", numChannels)
+		}
+		if queueConsumers > 0 || pipelineFile != "" || commandFlag != "" || pipelineDepth > 1 || hybridFraction > 0 || adaptiveOpMix || encodingCompare || phaseB != "" || ramp || preload {
+			return fmt.Errorf("-mode pubsub cannot be combined with -queue-consumers, -command-pipeline-file, -command, -pipeline, -hybrid-pipeline-fraction, -adaptive-mix, -encoding-compare, -phase-b, -ramp or -preload")
+		}
+	case modeScan:
+		if clusterMode {
+			return fmt.Errorf("-mode scan cannot be combined with -cluster: SCAN only covers the node it is sent to")
+		}
+		if scanCount < 0 {
+			return fmt.Errorf("-scan-count cannot be negative, got %d", scanCount)
+		}
+		if queueConsumers > 0 || pipelineFile != "" || commandFlag != "" || pipelineDepth > 1 || hybridFraction > 0 || adaptiveOpMix || encodingCompare || phaseB != "" || ramp {
+			return fmt.Errorf("-mode scan cannot be combined with -queue-consumers, -command-pipeline-file, -command, -pipeline, -hybrid-pipeline-fraction, -adaptive-mix, -encoding-compare, -phase-b or -ramp")
+		}
+	default:
+		return fmt.Errorf("Invalid -mode %q: expected kv, pubsub or scan", benchMode)
+	}
+	if queueConsumers < 0 || (queueConsumers > 0 && queueConsumers >= numClients) {
+		return fmt.Errorf("-queue-consumers must leave at least one of the %d clients as a producer, got %d", numClients, queueConsumers)
+	}
+	if queueConsumers > 0 && (queueTimeout < time.Second || queueTimeout%time.Second != 0) {
+		return fmt.Errorf("Invalid -queue-timeout %v: BLPOP needs a whole number of seconds", queueTimeout)
+	}
+
+	if keyspaceHitRatio > 1 {
+		return fmt.Errorf("-keyspace-hit-ratio must be at most 1, got %v", keyspaceHitRatio)
+	}
+
+	if faultRate < 0 || faultRate > 1 {
+		return fmt.Errorf("-fault-rate must be between 0 and 1, got %v", faultRate)
+	}
+	switch faultMode {
+	case "fail", "delay", "mixed":
+	default:
+		return fmt.Errorf("Invalid -fault-mode %q: expected fail, delay or mixed", faultMode)
+	}
+
+	if commandFlag != "" {
+		commandTemplate = strings.Fields(commandFlag)
+		if len(commandTemplate) == 0 {
+			return fmt.Errorf("-command is empty")
+		}
+		if pipelineFile != "" || queueConsumers > 0 || pipelineDepth > 1 || hybridFraction > 0 || adaptiveOpMix || encodingCompare {
+			return fmt.Errorf("-command cannot be combined with -command-pipeline-file, -queue-consumers, -pipeline, -hybrid-pipeline-fraction, -adaptive-mix or -encoding-compare")
+		}
+	}
+	if pipelineFile != "" {
+		commands, err := loadPipelineFile(pipelineFile)
+		if err != nil {
+			return fmt.Errorf("Invalid -command-pipeline-file: %v", err)
+		}
+		pipelineCommands = commands
+	}
+
+	var stopAtTime time.Time
+	if stopAt != "" {
+		t, err := time.Parse(time.RFC3339, stopAt)
+		if err != nil {
+			return fmt.Errorf("Invalid -stop-at %q: %v", stopAt, err)
+		}
+		if !t.After(time.Now()) {
+			return fmt.Errorf("-stop-at %s is in the past", stopAt)
+		}
+		stopAtTime = t
+	}
+
+	var reportTmpl *template.Template
+	if reportTemplate != "" {
+		tmpl, err := loadReportTemplate(reportTemplate)
+		if err != nil {
+			return fmt.Errorf("Invalid -report-template %q: %v", reportTemplate, err)
+		}
+		reportTmpl = tmpl
+	}
+
+	var baseline Report
+	if compareFile != "" {
+		if encodingCompare || ramp || comparePooling || phaseB != "" || tcpConnectOnly {
+			return fmt.Errorf("-compare cannot be combined with -encoding-compare, -ramp, -compare-pooling, -phase-b or -tcp-connect-only")
+		}
+		if regressionThreshold < 0 {
+			return fmt.Errorf("-regression-threshold must not be negative, got %v", regressionThreshold)
+		}
+		r, err := readReport(compareFile)
+		if err != nil {
+			return fmt.Errorf("Failed to read -compare baseline: %v", err)
+		}
+		baseline = r
+	}
+
+	if tcpConnectOnly {
+		runTCPProbe(redisAddr, numClients, testDuration)
+		return nil
+	}
+
+	ratios := opRatios{
+		set: setRatio, get: getRatio, del: delRatio,
+		idletime: idletimeRatio, freq: freqRatio, lmpop: lmpopRatio, zmpop: zmpopRatio,
+		incr: incrRatio, hset: hsetRatio, lpush: lpushRatio,
+	}
+	if ratios.sum() == 0 {
+		return fmt.Errorf("At least one operation ratio must be positive")
+	}
+	if ratios.set < 0 || ratios.get < 0 || ratios.del < 0 || ratios.idletime < 0 || ratios.freq < 0 ||
+		ratios.lmpop < 0 || ratios.zmpop < 0 || ratios.incr < 0 || ratios.hset < 0 || ratios.lpush < 0 {
+		return fmt.Errorf("Operation ratios must not be negative")
+	}
+	var phaseBRatios opRatios
+	phaseBValueSize := 0
+	if phaseB != "" {
+		if stopAt != "" {
+			return fmt.Errorf("-phase-b cannot be combined with -stop-at")
+		}
+		r, size, err := parsePhaseB(phaseB, ratios)
+		if err != nil {
+			return fmt.Errorf("Invalid -phase-b %q: %v", phaseB, err)
+		}
+		if valueSizeMax > 0 && size > valueSizeMax {
+			return fmt.Errorf("Invalid -phase-b %q: value-size %d exceeds -value-size-max %d", phaseB, size, valueSizeMax)
+		}
+		phaseBRatios, phaseBValueSize = r, size
+	}
+	if pipelineDepth > 1 || hybridFraction > 0 {
+		if skipped := batchSkippedFlags(ratios, phaseBRatios); len(skipped) > 0 {
+			return fmt.Errorf("-pipeline and -hybrid-pipeline-fraction cannot be combined with %s", strings.Join(skipped, ", "))
+		}
+	}
+
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+
+	if dumpConfigFile != "" {
+		if err := dumpConfig(dumpConfigFile, ratios); err != nil {
+			return fmt.Errorf("Failed to write -dump-config file: %v", err)
+		}
+		logger.Info("Configuration written", "file", dumpConfigFile)
+	}
+
+	progressOut = os.Stdout
+	if progressFile != "" {
+		f, err := os.Create(progressFile)
+		if err != nil {
+			return fmt.Errorf("Failed to create progress file: %v", err)
+		}
+		defer f.Close()
+		progressOut = f
+	}
+
+	if spawnBinary != "" {
+		server, err := spawnServer(spawnBinary, spawnConfig, redisAddr)
+		if err != nil {
+			return fmt.Errorf("Failed to spawn %s: %v", spawnBinary, err)
+		}
+		// -max-p99 aborts from the monitor goroutine, past this defer
+		atExit(server.stop)
+		defer func() {
+			server.stop()
+			logger.Info("Spawned redis-server stopped", "pid", server.pid())
+		}()
+		logger.Info("Spawned redis-server", "pid", server.pid(), "addr", redisAddr)
+	} else if spawnConfig != "" {
+		return fmt.Errorf("-spawn-config requires -spawn-server")
+	}
+
+	opts := &redis.Options{
+		Network:  redisNetwork,
 		Addr:     redisAddr,
+		Username: redisUser,
 		Password: redisPass,
 		DB:       redisDB,
-	})
+
+		PoolSize:     poolSize,
+		MinIdleConns: minIdleConns,
+		PoolTimeout:  poolTimeout,
+
+		MaxRetries: maxRetries,
+	}
+	if retryBackoff > 0 {
+		opts.MinRetryBackoff = retryBackoff
+		opts.MaxRetryBackoff = 64 * retryBackoff
+	}
+	if injectLatency > 0 {
+		opts.Dialer = delayDialer(injectLatency)
+	}
+	// Connection errors are told apart from command errors, including
+	// injected faults, and reconnects are counted at the dialer
+	connErrors = &connErrorTracker{}
+	opts.Dialer = connErrors.dialer(opts.Dialer)
+	if tlsConfig != nil {
+		opts.TLSConfig = tlsConfig
+		opts.Dialer = tlsDialer(tlsConfig, opts.Dialer)
+	}
+	if measureTTFB {
+		ttfb = newTTFBStats()
+		opts.Dialer = ttfbDialer(ttfb, opts.Dialer)
+	}
+	if killInterval > 0 {
+		churn = newChurnMonitor()
+		opts.Dialer = churn.dialer(opts.Dialer)
+		opts.OnConnect = churn.onConnect
+	}
+	// Without -clients, -rate decides how many clients are needed, though
+	// never fewer than the default. Ramps, pub/sub and queues divide their
+	// clients by other rules.
+	if targetRate > 0 && !flagGiven("clients") && !ramp && benchMode != modePubSub && queueConsumers == 0 {
+		n, latency, err := estimateClients(context.Background(), opts)
+		if err != nil {
+			return fmt.Errorf("Failed to estimate -clients from -rate: %v", err)
+		}
+		numClients = max(n, numClients)
+		if !flagGiven("pool-size") {
+			poolSize = max(numClients, minIdleConns)
+			opts.PoolSize = poolSize
+		}
+		if partitionKeys && numKeys < numClients {
+			return fmt.Errorf("-partition-keys needs at least one key per client: -keys %d is less than the %d clients derived from -rate; set -clients", numKeys, numClients)
+		}
+		logger.Info("Derived -clients from -rate by Little's Law", "clients", numClients,
+			"estimate", n, "rate", targetRate, "latency_ms", fmt.Sprintf("%.3f", latency), "headroom", rateClientHeadroom)
+	}
+	rdb := newBenchClient(opts)
 	defer rdb.Close()
 
+	clients := opClients{set: rdb, get: rdb, del: rdb}
+	if isolateOps {
+		clients.get = newBenchClient(opts)
+		defer clients.get.Close()
+		clients.del = newBenchClient(opts)
+		defer clients.del.Close()
+	}
+
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
 	// Verify connection
-	if err := rdb.Ping(ctx).Err(); err != nil {
-		log.Fatalf("Failed to connect to Redis: %v", err)
+	nodes, err := pingNodes(ctx, rdb)
+	if err != nil {
+		if isAuthError(err) {
+			return fmt.Errorf("Authentication failed as %s: %v (check -user and -pass)", authUser(), err)
+		}
+		return fmt.Errorf("Failed to connect to Redis: %v", err)
+	}
+	if clusterMode {
+		logger.Info("Connected to Redis Cluster", "masters", nodes)
+	}
+	if sentinelMaster != "" {
+		logger.Info("Connected to master through Sentinel", "master", sentinelMaster)
+	}
+	if databases != nil {
+		dbClients, closeDBs, err := databaseClients(ctx, opts, databases)
+		if err != nil {
+			return fmt.Errorf("Failed to connect to -dbs: %v", err)
+		}
+		defer closeDBs()
+		clients.dbs = dbClients
+		logger.Info("Workers spread across databases", "dbs", strings.Trim(fmt.Sprint(databases), "[]"))
+	}
+	if dedicatedConns {
+		n := max(numClients, rampMax)
+		workers, closeWorkers, err := dedicatedClients(ctx, opts, clients, n)
+		if err != nil {
+			return fmt.Errorf("Failed to open dedicated connections: %v", err)
+		}
+		defer closeWorkers()
+		clients.dedicated = workers
+		logger.Info("Opened dedicated connections, one per worker", "connections", n)
+	}
+
+	if len(requirements) > 0 {
+		if err := checkRequirements(ctx, rdb, requirements); err != nil {
+			return fmt.Errorf("%v", err)
+		}
+	}
+
+	if waitAOF {
+		version, err := serverVersion(ctx, rdb)
+		if err != nil {
+			return fmt.Errorf("Failed to detect Redis version: %v", err)
+		}
+		if !versionAtLeast(version, 7, 2) {
+			return fmt.Errorf("-waitaof requires Redis 7.2 or newer, server is %q", version)
+		}
 	}
 
-	fmt.Println("Starting Redis benchmark...")
+	if benchMode == modeScan && scanType != "" {
+		version, err := serverVersion(ctx, rdb)
+		if err != nil {
+			return fmt.Errorf("Failed to detect Redis version: %v", err)
+		}
+		if !versionAtLeast(version, 6, 0) {
+			return fmt.Errorf("-scan-type requires Redis 6.0 or newer, server is %q", version)
+		}
+	}
 
-	var wg sync.WaitGroup
-	var totalSet, totalGet, totalDel int
-	var lock sync.Mutex
+	if ratios.lmpop+phaseBRatios.lmpop > 0 || ratios.zmpop+phaseBRatios.zmpop > 0 {
+		version, err := serverVersion(ctx, rdb)
+		if err != nil {
+			return fmt.Errorf("Failed to detect Redis version: %v", err)
+		}
+		if !versionAtLeast(version, 7, 0) {
+			return fmt.Errorf("-lmpop and -zmpop require Redis 7.0 or newer, server is %q", version)
+		}
+		if clusterMode {
+			return fmt.Errorf("-lmpop and -zmpop cannot be used with -cluster: their keys span hash slots")
+		}
+		if err := preloadMPopKeys(ctx, rdb, mpopPreload); err != nil {
+			return fmt.Errorf("Failed to preload LMPOP/ZMPOP keys: %v", err)
+		}
+	}
 
-	// Statistics
-	setStats := operationStats{minTime: math.MaxFloat64}
-	getStats := operationStats{minTime: math.MaxFloat64}
-	delStats := operationStats{minTime: math.MaxFloat64}
+	if ratios.idletime+phaseBRatios.idletime > 0 || ratios.freq+phaseBRatios.freq > 0 {
+		policy, err := rdb.ConfigGet(ctx, "maxmemory-policy").Result()
+		if err != nil || len(policy) != 2 {
+			return fmt.Errorf("Failed to read maxmemory-policy: %v", err)
+		}
+		lfu := strings.Contains(fmt.Sprint(policy[1]), "lfu")
+		if ratios.freq+phaseBRatios.freq > 0 && !lfu {
+			return fmt.Errorf("-freq requires an LFU maxmemory-policy, server uses %v", policy[1])
+		}
+		if ratios.idletime+phaseBRatios.idletime > 0 && lfu {
+			return fmt.Errorf("-idletime requires a non-LFU maxmemory-policy, server uses %v", policy[1])
+		}
+	}
+
+	// The threshold monitor goes first so it also sees injected faults
+	var monitor *thresholdMonitor
+	if maxP99 > 0 || maxErrorRate > 0 {
+		monitor = &thresholdMonitor{}
+		clients.addHook(monitor)
+	}
+
+	clients.addHook(connErrors)
+	if connErrorsFile != "" {
+		f, err := os.Create(connErrorsFile)
+		if err != nil {
+			return fmt.Errorf("Failed to create connection error CSV: %v", err)
+		}
+		defer f.Close()
+		connErrorsOut = newConnErrorsCSV(f)
+	}
+	if metricsAddr != "" {
+		m, err := newBenchMetrics()
+		if err != nil {
+			return fmt.Errorf("Failed to set up metrics: %v", err)
+		}
+		shutdown, err := m.serve(metricsAddr)
+		if err != nil {
+			return fmt.Errorf("Failed to start metrics server: %v", err)
+		}
+		defer shutdown()
+		metrics = m
+		logger.Info("Serving metrics", "url", "http://"+metricsAddr+"/metrics")
+	}
+	if csvFile != "" {
+		f, err := os.Create(csvFile)
+		if err != nil {
+			return fmt.Errorf("Failed to create CSV file: %v", err)
+		}
+		defer f.Close()
+		csvOut = newIntervalCSV(f)
+	}
+
+	if bgsaveAt > 0 {
+		// BGSAVE and the INFO polling use their own connection so they are
+		// not counted as benchmark latency
+		adminOpts := *opts
+		adminOpts.OnConnect = nil
+		adminOpts.PoolSize = 1
+		admin := newServerClient(&adminOpts)
+		defer admin.Close()
+		bgsave = newBGSaveProbe(admin)
+		clients.addHook(bgsave)
+	}
+
+	if serverStats {
+		// INFO is sampled on its own connection so it is not counted as
+		// benchmark latency
+		adminOpts := *opts
+		adminOpts.OnConnect = nil
+		adminOpts.PoolSize = 1
+		admin := newServerClient(&adminOpts)
+		defer admin.Close()
+		serverStatsMon = newServerStatsMonitor(admin)
+	}
+
+	// Fault injection starts after the connection checks above
+	if faultRate > 0 {
+		faults = newFaultHook(faultRate, faultMode, faultDelay, faultSeed)
+		clients.addHook(faults)
+	}
+
+	if verifyChecksum {
+		checksums = newChecksumStore(numClients)
+	}
+
+	if churn != nil {
+		clients.addHook(churn)
+
+		// The killer uses its own unnamed connection so it is never a target
+		adminOpts := *opts
+		adminOpts.OnConnect = nil
+		adminOpts.PoolSize = 1
+		admin := newServerClient(&adminOpts)
+		defer admin.Close()
+		stopKiller := make(chan struct{})
+		defer close(stopKiller)
+		go churn.killLoop(ctx, admin, killInterval, stopKiller)
+	}
+	if keyspaceHitRatio >= 0 {
+		tracker = newKeyTracker(numKeys)
+	}
+
+	logger.Info("Starting Redis benchmark", "seed", seed)
+	logPoolConfig(rdb)
+	if valuePrefix != "" {
+		logger.Info("Writing values with a prefix", "prefix", valuePrefix)
+	}
 
 	keys := generateKeys(numKeys, keyPrefix)
 
-	// Progress tracking
-	progress := make([]map[string]int, numClients)
-	for i := range progress {
-		progress[i] = map[string]int{"set": 0, "get": 0, "del": 0}
+	// Registered before the runs, so it also follows the early returns of
+	// the comparison modes.
+	if cleanup {
+		defer func() {
+			removed, err := cleanupKeys(ctx, opts, keys)
+			if err != nil {
+				logger.Error("Cleanup failed", "removed", removed, "error", err)
+				return
+			}
+			logger.Info("Cleanup removed the benchmark keys", "removed", removed)
+		}()
+	}
+
+	if preload {
+		logger.Info("Preloading keys", "keys", len(keys))
+		took, err := preloadKeys(ctx, clients, keys)
+		if err != nil {
+			return fmt.Errorf("Preload failed: %v", err)
+		}
+		logger.Info("Preload finished", "took", took.Round(time.Millisecond))
 	}
 
-	// Signal channel to stop clients
-	stop := make(chan struct{})
+	if connectionWarmup {
+		printConnectionWarmup(ctx, clients)
+	}
 
-	// Start client workers
-	for i := 0; i < numClients; i++ {
-		wg.Add(1)
-		go clientWorker(ctx, rdb, keys, ttl, setRatio, getRatio, delRatio, progress[i],
-			&totalSet, &totalGet, &totalDel, &lock, stop, &setStats, &getStats, &delStats, &totalSetData, &totalGetData, &wg)
+	if calibrateOps > 0 {
+		f, err := calibrateLatencyFloor(ctx, rdb, calibrateOps)
+		if err != nil {
+			return fmt.Errorf("Latency floor calibration failed: %v", err)
+		}
+		floors = f
+		for _, f := range floors {
+			fmt.Printf("%s latency floor (ms): Min=%.3f, P50=%.3f\n", f.name, f.min, f.p50)
+		}
+	}
 
+	if warmupFixed > 0 {
+		logger.Info("Warming up", "duration", warmupFixed)
+		fixedWarmup(ctx, ratios, clients, keys, warmupFixed)
+	} else if warmupAdaptive {
+		logger.Info("Warming up until throughput stabilizes")
+		printWarmupResult(adaptiveWarmup(ctx, ratios, clients, keys))
 	}
 
-	// Start statistics reporter
-	go reportProgress(progress, &totalSet, &totalGet, &totalDel, stop)
+	// With -stop-at the run lasts until the requested wall-clock time
+	if !stopAtTime.IsZero() {
+		testDuration = time.Until(stopAtTime)
+		logger.Info("Running until -stop-at", "time", stopAtTime.Format(time.RFC3339))
+	}
 
-	// Run for the specified duration
-	time.Sleep(testDuration)
+	// The HDR log covers the measured runs only, not warmup and calibration
+	if hdrLogFile != "" {
+		hdr, err := newHDRLogger(hdrLogFile)
+		if err != nil {
+			return fmt.Errorf("Failed to create HDR histogram log: %v", err)
+		}
+		clients.addHook(hdr)
+		hdr.run(hdrLogInterval)
+		defer func() {
+			if err := hdr.close(); err != nil {
+				logger.Error("Failed to write HDR histogram log", "error", err)
+			}
+		}()
+	}
 
-	// Signal workers to stop
-	close(stop)
+	if monitor != nil {
+		stopMonitor := make(chan struct{})
+		defer close(stopMonitor)
+		go monitor.watch(stopMonitor)
+	}
 
-	// Wait for all workers to finish
-	wg.Wait()
+	catchSignals()
 
-	fmt.Println("\nBenchmark complete.")
-	fmt.Printf("Total clients: %d\n", numClients)
-	fmt.Printf("Total keys: %d\n", numKeys)
-	fmt.Printf("Total time: %v\n", testDuration)
-	fmt.Printf("SET operations: %d\n", totalSet)
-	fmt.Printf("GET operations: %d\n", totalGet)
-	fmt.Printf("DEL operations: %d\n", totalDel)
-	fmt.Printf("Total data sent during SET operations: %.2f MB\n", float64(totalSetData)/(1024*1024))
-	fmt.Printf("Total data retrieved during GET operations: %.2f MB\n", float64(totalGetData)/(1024*1024))
-	fmt.Printf("Average SET ops/sec: %.2f\n", float64(totalSet)/testDuration.Seconds())
-	fmt.Printf("Average GET ops/sec: %.2f\n", float64(totalGet)/testDuration.Seconds())
-	fmt.Printf("Average DEL ops/sec: %.2f\n", float64(totalDel)/testDuration.Seconds())
+	if encodingCompare {
+		runEncodingComparison(ctx, ratios, clients, keys, reportTmpl)
+		return nil
+	}
 
-	// Print latency statistics
-	printStats("SET", &setStats)
-	printStats("GET", &getStats)
-	printStats("DEL", &delStats)
+	if ramp {
+		runRamp(ctx, ratios, clients, keys)
+		return nil
+	}
+
+	if comparePooling {
+		runPoolingComparison(ctx, ratios, opts, clients, keys, reportTmpl)
+		return nil
+	}
+
+	if phaseB == "" {
+		run := newBenchmarkRun(ratios, numClients)
+		run.execute(ctx, clients, keys, testDuration)
+		run.printSummary(clients, reportTmpl)
+		sendResults(run)
+		if benchMode == modeScan && scanBaseline && (scanMatch != "" || scanType != "") && !run.interrupted {
+			runScanBaseline(ctx, ratios, clients, keys, run)
+		}
+		if compareFile != "" && !run.interrupted {
+			regressionDetected = compareToBaseline(compareFile, baseline, run.report())
+		}
+		return nil
+	}
+
+	fmt.Println("Phase A: base operation mix")
+	runA := newBenchmarkRun(ratios, numClients)
+	runA.execute(ctx, clients, keys, testDuration)
+	runA.printSummary(clients, reportTmpl)
+	sendResults(runA)
+	if runA.interrupted {
+		return nil
+	}
+
+	fmt.Printf("\nPhase B: %s\n", phaseB)
+	runB := newBenchmarkRun(phaseBRatios, numClients)
+	if phaseBValueSize > 0 {
+		runB.valueSize = phaseBValueSize
+	}
+	runB.execute(ctx, clients, keys, testDuration)
+	runB.printSummary(clients, reportTmpl)
+	sendResults(runB)
+
+	printPhaseComparison(runA, runB)
+	return nil
+}
+
+// sendResults records the report of a finished run in -history-db and posts
+// it to -results-callback-url, when set, and prints whether it succeeded.
+func sendResults(run *benchmarkRun) {
+	if historyDB != "" {
+		if err := appendHistory(historyDB, run); err != nil {
+			logger.Error("Failed to record run", "db", historyDB, "error", err)
+		} else {
+			logger.Info("Run recorded", "db", historyDB)
+		}
+	}
+	if callbackURL == "" {
+		return
+	}
+	if err := postResults(callbackURL, run.report(), callbackTimeout, callbackRetries); err != nil {
+		logger.Error("Results callback failed", "url", callbackURL, "error", err)
+		return
+	}
+	logger.Info("Results posted", "url", callbackURL)
+}
+
+// resolvePassword picks the Redis password from -pass or -pass-file, which
+// are mutually exclusive, or else from the REDIS_PASSWORD environment
+// variable.
+func resolvePassword() (string, error) {
+	if redisPass != "" && redisPassFile != "" {
+		return "", fmt.Errorf("-pass and -pass-file are mutually exclusive")
+	}
+	if redisPass != "" {
+		return redisPass, nil
+	}
+	if redisPassFile != "" {
+		data, err := os.ReadFile(redisPassFile)
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimRight(string(data), "\r\n"), nil
+	}
+	return os.Getenv("REDIS_PASSWORD"), nil
+}
+
+// isAuthError reports whether err is the server rejecting the credentials
+// or the connection needing them.
+func isAuthError(err error) bool {
+	msg := err.Error()
+	for _, prefix := range []string{"WRONGPASS", "NOAUTH", "NOPERM", "ERR AUTH", "ERR invalid password", "ERR Client sent AUTH"} {
+		if strings.HasPrefix(msg, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// authUser describes the user the benchmark authenticates as.
+func authUser() string {
+	if redisUser == "" {
+		return "the default user"
+	}
+	return fmt.Sprintf("user %q", redisUser)
 }
 
 func generateKeys(numKeys int, prefix string) []string {
@@ -143,76 +1351,308 @@ func generateKeys(numKeys int, prefix string) []string {
 	return keys
 }
 
-func randomString(n int) string {
+// workerRand returns the random generator of one worker. Seeding it from
+// -seed plus the worker ID keeps workers on distinct sequences while making
+// a run reproducible.
+func workerRand(workerID int) *rand.Rand {
+	return rand.New(rand.NewSource(seed + int64(workerID)))
+}
+
+func randomString(rng *rand.Rand, n int) string {
 	letters := []rune("abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789")
 	b := make([]rune, n)
 	for i := range b {
-		b[i] = letters[rand.Intn(len(letters))]
+		b[i] = letters[rng.Intn(len(letters))]
 	}
 	return string(b)
 }
 
+// setTTL returns the TTL for one SET: -ttl, moved by up to -ttl-jitter in
+// whole milliseconds either way, for a -ttl-fraction share of writes and no
+// expiry for the rest. A -ttl of 0 writes every key without expiry.
+func setTTL(rng *rand.Rand) time.Duration {
+	if ttl == 0 || ttlFraction < 1 && rng.Float64() >= ttlFraction {
+		return 0
+	}
+	if ttlJitter == 0 {
+		return ttl
+	}
+	jitter := ttlJitter.Milliseconds()
+	return ttl + time.Duration(rng.Int63n(2*jitter+1)-jitter)*time.Millisecond
+}
+
+// generateValue builds a value of the given size. When -value-prefix is set
+// the value starts with that marker (plus worker id and timestamp with
+// -value-prefix-meta) and the remaining bytes are random filler.
+func generateValue(rng *rand.Rand, workerID, size int) string {
+	return string(appendValue(rng, nil, workerID, size))
+}
+
+// appendValue appends a value built like generateValue to buf, so callers
+// can reuse buffers from valuePool.
+func appendValue(rng *rand.Rand, buf []byte, workerID, size int) []byte {
+	const letters = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+
+	start := len(buf)
+	if valuePrefix != "" {
+		if valuePrefixMeta {
+			buf = fmt.Appendf(buf, "%s:w%d:%d:", valuePrefix, workerID, time.Now().UnixNano())
+		} else {
+			buf = append(buf, valuePrefix...)
+		}
+	}
+	for len(buf)-start < size {
+		buf = append(buf, letters[rng.Intn(len(letters))])
+	}
+	return buf
+}
+
 func clientWorker(
 	ctx context.Context,
-	rdb *redis.Client,
+	workerID int,
+	clients opClients,
 	keys []string,
-	ttl time.Duration,
-	setRatio, getRatio, delRatio float64,
-	progress map[string]int,
-	totalSet, totalGet, totalDel *int,
-	lock *sync.Mutex,
+	run *benchmarkRun,
 	stop <-chan struct{},
-	setStats, getStats, delStats *operationStats,
-	totalSetData, totalGetData *int64, // New parameters for data size
 	wg *sync.WaitGroup,
 ) {
 	defer wg.Done()
 
-	rand.Seed(time.Now().UnixNano())
+	ratios := run.ratios
+	counters := &run.counters[workerID-1]
+	stats := run.workerStats[workerID-1]
+
+	batchChance := 0.0
+	if hybridFraction > 0 {
+		batchChance = hybridBatchChance()
+	}
+
+	paceCtx, cancelPace := stopContext(ctx, stop)
+	defer cancelPace()
+
+	rng := workerRand(workerID)
+	picker := workerKeyPicker(rng, workerID, len(run.counters), len(keys))
 	for {
 		select {
 		case <-stop:
 			return
 		default:
-			op := rand.Float64()
-			key := keys[rand.Intn(len(keys))]
+			if pipelineDepth > 1 {
+				n := run.pace(paceCtx, pipelineDepth)
+				if n == 0 {
+					return
+				}
+				hybridBatch(ctx, rng, workerID, clients.set, keys, picker, run, n)
+				continue
+			}
+			if batchChance > 0 && rng.Float64() < batchChance {
+				n := run.pace(paceCtx, hybridDepth)
+				if n == 0 {
+					return
+				}
+				hybridBatch(ctx, rng, workerID, clients.set, keys, picker, run, n)
+				continue
+			}
+			if run.pace(paceCtx, 1) == 0 {
+				return
+			}
 
-			if op < setRatio {
+			if run.mix != nil {
+				ratios = run.mix.ratios()
+			}
+			var op float64
+			if run.schedule != nil {
+				op = run.schedule.pick()
+			} else {
+				op = rng.Float64()
+			}
+			keyIndex := picker.next()
+			key := keys[keyIndex]
+
+			if op < ratios.set {
 				// SET operation
-				value := randomString(100) // Assuming 100 bytes per value
+				if oomPause > 0 && run.oom.paused() {
+					time.Sleep(time.Millisecond)
+					continue
+				}
+				if batchSize > 1 {
+					msetBatch(ctx, rng, workerID, clients.set, keys, key, picker, run, 1+run.claim(batchSize-1))
+					continue
+				}
+				buf := getValueBuffer()
+				var value []byte
+				if run.intValues {
+					value = strconv.AppendInt((*buf)[:0], rng.Int63n(1e9), 10)
+				} else {
+					value = appendValue(rng, (*buf)[:0], workerID, run.setValueSize(rng))
+				}
+				*buf = value
+				if checksums != nil {
+					checksums.writing(key, value)
+				}
+				keyTTL := setTTL(rng)
 				start := time.Now()
-				if err := rdb.Set(ctx, key, value, ttl).Err(); err == nil {
+				err := clients.set.Set(ctx, key, value, keyTTL).Err()
+				putValueBuffer(buf)
+				if oomPause > 0 {
+					run.oom.record(err)
+				}
+				if err == nil {
+					if tracker != nil {
+						tracker.set(keyIndex, keyTTL)
+					}
 					duration := time.Since(start).Seconds() * 1000
-					updateStats(setStats, duration)
-					lock.Lock()
-					progress["set"]++
-					*totalSet++
-					*totalSetData += int64(len(value)) + int64(len(key)) // Data size includes key and value
-					lock.Unlock()
+					updateStats(&stats.set, duration)
+					run.recordSizeTier(stats, false, len(value), duration)
+					atomic.AddInt64(&counters.set, 1)
+					if keyTTL > 0 {
+						atomic.AddInt64(&counters.volatileSets, 1)
+					}
+					atomic.AddInt64(&counters.setBytes, int64(len(value))+int64(len(key)))
+
+					if waitAOF {
+						start := time.Now()
+						acks, err := clients.set.Do(ctx, "WAITAOF", waitAOFLocal, waitAOFReplicas, waitAOFTimeout.Milliseconds()).Int64Slice()
+						if err == nil && len(acks) == 2 {
+							updateStats(&stats.waitAOF, time.Since(start).Seconds()*1000)
+							if acks[0] >= int64(waitAOFLocal) && acks[1] >= int64(waitAOFReplicas) {
+								atomic.AddInt64(&counters.waitAOFAchieved, 1)
+							}
+						} else {
+							recordError(&stats.waitAOF)
+						}
+					}
+				} else {
+					recordError(&stats.set)
 				}
-			} else if op < setRatio+getRatio {
+			} else if op < ratios.set+ratios.get {
 				// GET operation
+				if batchSize > 1 {
+					mgetBatch(ctx, workerID, clients.get, keys, key, picker, run, 1+run.claim(batchSize-1))
+					continue
+				}
+				if tracker != nil {
+					if rng.Float64() < keyspaceHitRatio {
+						if i, ok := tracker.pickExisting(rng); ok {
+							key = keys[i]
+						}
+					} else {
+						key = missKey(rng, keyPrefix)
+					}
+				}
 				start := time.Now()
-				result, err := rdb.Get(ctx, key).Result()
+				var result string
+				var err error
+				if refreshTTLOnGet == "getex" {
+					result, err = clients.get.GetEx(ctx, key, ttl).Result()
+				} else {
+					result, err = clients.get.Get(ctx, key).Result()
+				}
 				if err == nil || err == redis.Nil {
 					duration := time.Since(start).Seconds() * 1000
-					updateStats(getStats, duration)
-					lock.Lock()
-					progress["get"]++
-					*totalGet++
-					*totalGetData += int64(len(result)) + int64(len(key)) // Data size includes key and value
-					lock.Unlock()
+					updateStats(&stats.get, duration)
+					if err == nil {
+						run.recordSizeTier(stats, true, len(result), duration)
+					}
+					if checksums != nil && err == nil {
+						checksums.read(key, result)
+					}
+					if refreshTTLOnGet == "expire" && err == nil {
+						start := time.Now()
+						if err := clients.get.Expire(ctx, key, ttl).Err(); err == nil {
+							updateStats(&stats.refresh, time.Since(start).Seconds()*1000)
+						}
+					}
+					atomic.AddInt64(&counters.get, 1)
+					if err == nil {
+						atomic.AddInt64(&counters.getHits, 1)
+					}
+					atomic.AddInt64(&counters.getBytes, int64(len(result))+int64(len(key)))
+				} else {
+					recordError(&stats.get)
 				}
-			} else {
-				// DEL operation
+			} else if op < ratios.set+ratios.get+ratios.del {
+				// DEL operation, or its EXPIRE replacement
 				start := time.Now()
-				if err := rdb.Del(ctx, key).Err(); err == nil {
+				var err error
+				if deleteStrategy == "expire" {
+					var set bool
+					set, err = clients.del.Expire(ctx, key, time.Second).Result()
+					if err == nil && set && tracker != nil {
+						tracker.set(keyIndex, time.Second)
+					}
+				} else {
+					err = clients.del.Del(ctx, key).Err()
+					if err == nil && tracker != nil {
+						tracker.del(keyIndex)
+					}
+				}
+				if err == nil {
 					duration := time.Since(start).Seconds() * 1000
-					updateStats(delStats, duration)
-					lock.Lock()
-					progress["del"]++
-					*totalDel++
-					lock.Unlock()
+					updateStats(&stats.del, duration)
+					atomic.AddInt64(&counters.del, 1)
+				} else {
+					recordError(&stats.del)
+				}
+			} else if op < ratios.set+ratios.get+ratios.del+ratios.idletime {
+				// OBJECT IDLETIME operation
+				start := time.Now()
+				idle, err := clients.get.ObjectIdleTime(ctx, key).Result()
+				if err == nil || err == redis.Nil {
+					updateStats(&stats.obj.idleLatency, time.Since(start).Seconds()*1000)
+					if err == nil {
+						updateStats(&stats.obj.idleValues, idle.Seconds())
+					}
+				} else {
+					recordError(&stats.obj.idleLatency)
+				}
+			} else if op < ratios.set+ratios.get+ratios.del+ratios.idletime+ratios.freq {
+				// OBJECT FREQ operation
+				start := time.Now()
+				freq, err := clients.get.Do(ctx, "OBJECT", "FREQ", key).Int64()
+				if err == nil || err == redis.Nil {
+					updateStats(&stats.obj.freqLatency, time.Since(start).Seconds()*1000)
+					if err == nil {
+						updateStats(&stats.obj.freqValues, float64(freq))
+					}
+				} else {
+					recordError(&stats.obj.freqLatency)
+				}
+			} else if op < ratios.set+ratios.get+ratios.del+ratios.idletime+ratios.freq+ratios.lmpop {
+				// LMPOP operation
+				mpop(ctx, clients.set, "LMPOP", mpopKeys("list"), "LEFT", &stats.lmpop, run)
+			} else if op < ratios.set+ratios.get+ratios.del+ratios.idletime+ratios.freq+ratios.lmpop+ratios.zmpop {
+				// ZMPOP operation
+				mpop(ctx, clients.set, "ZMPOP", mpopKeys("zset"), "MIN", &stats.zmpop, run)
+			} else if op < ratios.set+ratios.get+ratios.del+ratios.idletime+ratios.freq+ratios.lmpop+ratios.zmpop+ratios.incr {
+				// INCR operation
+				start := time.Now()
+				if err := clients.set.Incr(ctx, key+counterKeySuffix).Err(); err == nil {
+					updateStats(&stats.incr, time.Since(start).Seconds()*1000)
+					atomic.AddInt64(&counters.incr, 1)
+				} else {
+					recordError(&stats.incr)
+				}
+			} else if op < ratios.set+ratios.get+ratios.del+ratios.idletime+ratios.freq+ratios.lmpop+ratios.zmpop+ratios.incr+ratios.hset {
+				// HSET operation
+				field := hashFields[rng.Intn(len(hashFields))]
+				value := generateValue(rng, workerID, valueSize.get("hset"))
+				start := time.Now()
+				if err := clients.set.HSet(ctx, key+hashKeySuffix, field, value).Err(); err == nil {
+					updateStats(&stats.hset, time.Since(start).Seconds()*1000)
+					atomic.AddInt64(&counters.hset, 1)
+				} else {
+					recordError(&stats.hset)
+				}
+			} else if ratios.lpush > 0 {
+				// LPUSH operation
+				value := generateValue(rng, workerID, valueSize.get("lpush"))
+				start := time.Now()
+				if err := clients.set.LPush(ctx, key+listKeySuffix, value).Err(); err == nil {
+					updateStats(&stats.lpush, time.Since(start).Seconds()*1000)
+					atomic.AddInt64(&counters.lpush, 1)
+				} else {
+					recordError(&stats.lpush)
 				}
 			}
 		}
@@ -231,49 +1671,343 @@ func updateStats(stats *operationStats, duration float64) {
 	if duration > stats.maxTime {
 		stats.maxTime = duration
 	}
+	stats.hist.record(duration)
+	if progressFormat == "json-lines" {
+		stats.interval.record(duration)
+	}
+	if stats.metrics != nil {
+		stats.metrics.latency.Observe(duration / 1000)
+		stats.metrics.ops.Inc()
+	}
 }
 
+// merge adds the samples recorded in o to s.
+func (s *operationStats) merge(o *operationStats) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.count += o.count
+	s.errors += o.errors
+	s.totalTime += o.totalTime
+	if o.minTime < s.minTime {
+		s.minTime = o.minTime
+	}
+	if o.maxTime > s.maxTime {
+		s.maxTime = o.maxTime
+	}
+	s.hist.merge(&o.hist)
+	s.interval.merge(&o.interval)
+}
+
+// printStats prints the latency statistics of an operation. Without any
+// completed operation there is nothing to report, and minTime still holds
+// its math.MaxFloat64 starting value, so it prints N/A instead.
 func printStats(operation string, stats *operationStats) {
 	stats.mu.Lock()
 	defer stats.mu.Unlock()
 
-	avgTime := 0.0
-	if stats.count > 0 {
-		avgTime = stats.totalTime / float64(stats.count)
+	if stats.count == 0 {
+		fmt.Printf("%s Latency (ms): N/A (no completed operations)\n", operation)
+		return
 	}
+	avgTime := stats.totalTime / float64(stats.count)
 
 	fmt.Printf("%s Latency (ms): Min=%.2f, Avg=%.2f, Max=%.2f\n",
 		operation, stats.minTime, avgTime, stats.maxTime)
+	fmt.Printf("%s Latency (ms): P50=%.2f, P95=%.2f, P99=%.2f, P99.9=%.2f\n",
+		operation, stats.hist.percentile(50), stats.hist.percentile(95), stats.hist.percentile(99), stats.hist.percentile(99.9))
+	if outlierRemoval > 0 {
+		fmt.Printf("%s Trimmed Avg (ms, dropping %.1f%% each side): %.2f\n",
+			operation, outlierRemoval, stats.hist.trimmedMean(outlierRemoval))
+	}
+}
+
+// printValueStats prints the distribution of values returned by a command,
+// such as the idle times observed by OBJECT IDLETIME.
+func printValueStats(operation, unit string, stats *operationStats) {
+	stats.mu.Lock()
+	defer stats.mu.Unlock()
+
+	if stats.count == 0 {
+		fmt.Printf("%s Values (%s): none observed\n", operation, unit)
+		return
+	}
+	fmt.Printf("%s Values (%s): Min=%.0f, Avg=%.2f, Max=%.0f, Observed=%d\n",
+		operation, unit, stats.minTime, stats.totalTime/float64(stats.count), stats.maxTime, stats.count)
 }
 
-func reportProgress(progress []map[string]int, totalSet, totalGet, totalDel *int, stop <-chan struct{}) {
+func printPoolStats(operation string, client benchClient) {
+	ps := client.PoolStats()
+	fmt.Printf("%s Pool: Hits=%d, Misses=%d, Timeouts=%d, TotalConns=%d, IdleConns=%d, StaleConns=%d\n",
+		operation, ps.Hits, ps.Misses, ps.Timeouts, ps.TotalConns, ps.IdleConns, ps.StaleConns)
+}
+
+// progressGridMaxClients is the most clients the live display gives a row
+// each; beyond that the grid no longer fits a terminal.
+const progressGridMaxClients = 50
+
+// isTerminal reports whether f is a terminal rather than a file or pipe.
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// reportProgress shows the live operation counts. On a terminal with few
+// enough clients it redraws a row per client and a total; otherwise it
+// shows only the total, rewritten in place on a terminal and as a new line
+// each second when stdout is redirected.
+func reportProgress(counters []workerCounters, stop <-chan struct{}) {
 	ticker := time.NewTicker(1 * time.Second)
 	defer ticker.Stop()
 
-	numClients := len(progress)
+	tty := isTerminal(os.Stdout)
+	grid := tty && len(counters) <= progressGridMaxClients
 
 	// Print initial rows
-	for i := 0; i < numClients; i++ {
-		fmt.Printf("Client %d: SET=0, GET=0, DEL=0\n", i+1)
+	if grid {
+		rows, totals := progressRows(counters)
+		for _, row := range rows {
+			fmt.Println(row)
+		}
+		fmt.Println(formatTotals(totals, nil))
+	}
+
+	// The counts of the previous tick, to turn the cumulative counts into
+	// per-interval rates, and the total rates of the last -progress-window
+	// intervals
+	rate := ema{alpha: progressAlpha}
+	lastTotal := 0
+	var lastCounts []int
+	lastTick := time.Now()
+	window := make([]float64, 0, progressWindow)
+
+	for {
+		select {
+		case <-stop:
+			if tty && !grid {
+				fmt.Println()
+			}
+			if tty {
+				fmt.Print("\033[0m") // Reset formatting
+			}
+			return
+		case now := <-ticker.C:
+			rows, totals := progressRows(counters)
+			elapsed := now.Sub(lastTick).Seconds()
+			lastTick = now
+			if lastCounts == nil {
+				lastCounts = make([]int, len(totals))
+			}
+			rates := make([]float64, len(totals))
+			for i, t := range totals {
+				rates[i] = float64(t.count-lastCounts[i]) / elapsed
+				lastCounts[i] = t.count
+			}
+
+			ops := 0
+			for i := range counters {
+				ops += counters[i].operations()
+			}
+			interval := float64(ops-lastTotal) / elapsed
+			lastTotal = ops
+			opsPerSec := rate.update(interval)
+			if len(window) == progressWindow {
+				window = window[1:]
+			}
+			window = append(window, interval)
+			windowSum := 0.0
+			for _, v := range window {
+				windowSum += v
+			}
+			total := fmt.Sprintf("%s, ops/sec=%.0f (EMA alpha %.2f), %ds avg=%.0f",
+				formatTotals(totals, rates), opsPerSec, progressAlpha, len(window), windowSum/float64(len(window)))
+
+			switch {
+			case grid:
+				// Move cursor up and print updated rows
+				fmt.Printf("\033[%dA", len(rows)+1)
+				for _, row := range rows {
+					fmt.Printf("\033[K%s\n", row)
+				}
+				fmt.Printf("\033[K%s\n", total)
+			case tty:
+				fmt.Printf("\r\033[K%s", total)
+			default:
+				fmt.Println(total)
+			}
+		}
+	}
+}
+
+// progressCount is the cumulative count of one operation type of the
+// progress total.
+type progressCount struct {
+	name  string
+	count int
+}
+
+// progressRows returns the live progress row of each client and the totals
+// of all clients, showing the operations of the running workload.
+func progressRows(counters []workerCounters) (rows []string, totals []progressCount) {
+	rows = make([]string, len(counters))
+	switch {
+	case benchMode == modePubSub:
+		published, received := 0, 0
+		for i := range counters {
+			pub, recv := loadCount(&counters[i].published), loadCount(&counters[i].received)
+			if i < numSubscribers {
+				rows[i] = fmt.Sprintf("Client %d: RECEIVED=%d", i+1, recv)
+			} else {
+				rows[i] = fmt.Sprintf("Client %d: PUBLISH=%d", i+1, pub)
+			}
+			published += pub
+			received += recv
+		}
+		return rows, []progressCount{{"PUBLISH", published}, {"RECEIVED", received}}
+	case benchMode == modeScan:
+		scans, iterations := 0, 0
+		for i := range counters {
+			n, it := loadCount(&counters[i].scan), loadCount(&counters[i].scanIterations)
+			rows[i] = fmt.Sprintf("Client %d: SCAN=%d, ITERATIONS=%d", i+1, n, it)
+			scans += n
+			iterations += it
+		}
+		return rows, []progressCount{{"SCAN", scans}, {"ITERATIONS", iterations}}
+	case queueConsumers > 0:
+		pushed, popped := 0, 0
+		for i := range counters {
+			lpush, blpop := loadCount(&counters[i].lpush), loadCount(&counters[i].blpop)
+			if i < queueConsumers {
+				rows[i] = fmt.Sprintf("Client %d: BLPOP=%d", i+1, blpop)
+			} else {
+				rows[i] = fmt.Sprintf("Client %d: LPUSH=%d", i+1, lpush)
+			}
+			pushed += lpush
+			popped += blpop
+		}
+		return rows, []progressCount{{"LPUSH", pushed}, {"BLPOP", popped}}
+	case commandTemplate != nil:
+		n := 0
+		for i := range counters {
+			c := loadCount(&counters[i].command)
+			rows[i] = fmt.Sprintf("Client %d: %s=%d", i+1, commandName(), c)
+			n += c
+		}
+		return rows, []progressCount{{commandName(), n}}
+	case pipelineCommands != nil:
+		pipelines := 0
+		for i := range counters {
+			n := loadCount(&counters[i].pipeline)
+			rows[i] = fmt.Sprintf("Client %d: PIPELINES=%d", i+1, n)
+			pipelines += n
+		}
+		return rows, []progressCount{{"PIPELINES", pipelines}}
+	}
+	for i := range counters {
+		c := &counters[i]
+		rows[i] = fmt.Sprintf("Client %d: SET=%d, GET=%d, DEL=%d", i+1, loadCount(&c.set), loadCount(&c.get), loadCount(&c.del))
+	}
+	set, get, del := setGetDel(counters)
+	return rows, []progressCount{{"SET", set}, {"GET", get}, {"DEL", del}}
+}
+
+// formatTotals formats the progress total row: the cumulative count of
+// each operation type, followed by its rate over the last interval when
+// rates is not nil.
+func formatTotals(totals []progressCount, rates []float64) string {
+	parts := make([]string, len(totals))
+	for i, t := range totals {
+		if rates == nil {
+			parts[i] = fmt.Sprintf("%s=%d", t.name, t.count)
+		} else {
+			parts[i] = fmt.Sprintf("%s=%d (%.0f/s)", t.name, t.count, rates[i])
+		}
+	}
+	return "Total: " + strings.Join(parts, ", ")
+}
+
+// ema is an exponential moving average. The first value is taken as is.
+type ema struct {
+	alpha  float64
+	value  float64
+	primed bool
+}
+
+func (e *ema) update(x float64) float64 {
+	if !e.primed {
+		e.value, e.primed = x, true
+	} else {
+		e.value = e.alpha*x + (1-e.alpha)*e.value
 	}
-	fmt.Println("Total: SET=0, GET=0, DEL=0")
+	return e.value
+}
+
+// intervalReport is one operation type's entry in a json-lines progress line.
+// Count is exact; the rates and latencies are smoothed with
+// -progress-ema-alpha.
+type intervalReport struct {
+	Count     uint64  `json:"count"`
+	OpsPerSec float64 `json:"ops_per_sec"`
+	P50Ms     float64 `json:"p50_ms"`
+	P95Ms     float64 `json:"p95_ms"`
+	P99Ms     float64 `json:"p99_ms"`
+}
+
+// reportProgressJSON writes one JSON object per second describing the
+// operations completed during that interval, for consumers such as
+// `tail -f | jq`. stats holds each worker's statistics per operation type,
+// whose interval histograms are merged and reset on every tick.
+func reportProgressJSON(out io.Writer, stats map[string][]*operationStats, stop <-chan struct{}) {
+	ticker := time.NewTicker(1 * time.Second)
+	defer ticker.Stop()
+
+	w := bufio.NewWriter(out)
+	enc := json.NewEncoder(w)
+	start := time.Now()
+	last := start
+	smoothed := map[string]*[4]ema{}
 
 	for {
 		select {
 		case <-stop:
-			fmt.Print("\033[0m") // Reset formatting
 			return
-		case <-ticker.C:
-			// Move cursor up
-			fmt.Printf("\033[%dA", numClients+1)
+		case now := <-ticker.C:
+			elapsed := now.Sub(last).Seconds()
+			last = now
 
-			// Print updated rows
-			for i, p := range progress {
-				fmt.Printf("\033[KClient %d: SET=%d, GET=%d, DEL=%d\n", i+1, p["set"], p["get"], p["del"])
+			line := map[string]interface{}{
+				"elapsed_sec": math.Round(now.Sub(start).Seconds()),
+				"ema_alpha":   progressAlpha,
+			}
+			for name, workers := range stats {
+				var interval latencyHistogram
+				for _, s := range workers {
+					s.mu.Lock()
+					interval.merge(&s.interval)
+					s.interval.reset()
+					s.mu.Unlock()
+				}
+				e, ok := smoothed[name]
+				if !ok {
+					e = &[4]ema{{alpha: progressAlpha}, {alpha: progressAlpha}, {alpha: progressAlpha}, {alpha: progressAlpha}}
+					smoothed[name] = e
+				}
+				line[name] = intervalReport{
+					Count:     interval.total,
+					OpsPerSec: e[0].update(float64(interval.total) / elapsed),
+					P50Ms:     e[1].update(interval.percentile(50)),
+					P95Ms:     e[2].update(interval.percentile(95)),
+					P99Ms:     e[3].update(interval.percentile(99)),
+				}
 			}
 
-			// Print updated total
-			fmt.Printf("\033[KTotal: SET=%d, GET=%d, DEL=%d\n", *totalSet, *totalGet, *totalDel)
+			if err := enc.Encode(line); err != nil {
+				logger.Error("Failed to write progress", "error", err)
+				return
+			}
+			w.Flush()
 		}
 	}
 }
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"runtime"
+	"runtime/debug"
+	"sync"
+)
+
+// valuePool recycles SET value buffers so large values at high throughput
+// do not leave a trail of garbage for the collector. A buffer belongs to a
+// single worker between getValueBuffer and putValueBuffer; go-redis has
+// finished writing the value to the connection by the time Set returns, so
+// the buffer can be reused immediately afterwards.
+var valuePool = sync.Pool{
+	New: func() interface{} {
+		b := make([]byte, 0, max(valueSize.get("set"), valueSizeMax))
+		return &b
+	},
+}
+
+func getValueBuffer() *[]byte {
+	return valuePool.Get().(*[]byte)
+}
+
+func putValueBuffer(b *[]byte) {
+	valuePool.Put(b)
+}
+
+// printMemStats reports the memory used by the benchmark process itself.
+// The Go runtime honors GOMEMLIMIT, so setting it keeps the client from
+// running out of memory when benchmarking large payloads.
+func printMemStats() {
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+
+	limit := "none"
+	if l := debug.SetMemoryLimit(-1); l != math.MaxInt64 {
+		limit = fmt.Sprintf("%.2f MB", float64(l)/(1024*1024))
+	}
+	fmt.Printf("Client memory: heap in use=%.2f MB, total from OS=%.2f MB, GC cycles=%d, GOMEMLIMIT=%s\n",
+		float64(m.HeapInuse)/(1024*1024), float64(m.Sys)/(1024*1024), m.NumGC, limit)
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+)
+
+// TestValuePoolConcurrentSets writes values built in pooled buffers from
+// concurrent workers, the way clientWorker does, and reads every one back.
+// Run with -race to also catch a buffer shared between workers.
+func TestValuePoolConcurrentSets(t *testing.T) {
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr(), PoolSize: 8})
+	defer client.Close()
+	ctx := context.Background()
+
+	const workers, setsPerWorker = 8, 200
+	var wg sync.WaitGroup
+	errs := make(chan error, workers)
+	for w := 1; w <= workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			rng := workerRand(w)
+			for i := 0; i < setsPerWorker; i++ {
+				key := fmt.Sprintf("pool:%d:%d", w, i)
+				buf := getValueBuffer()
+				value := appendValue(rng, (*buf)[:0], w, 1+rng.Intn(4096))
+				*buf = value
+				want := string(value)
+				err := client.Set(ctx, key, value, 0).Err()
+				putValueBuffer(buf)
+				if err != nil {
+					errs <- err
+					return
+				}
+				got, err := client.Get(ctx, key).Result()
+				if err != nil {
+					errs <- err
+					return
+				}
+				if got != want {
+					errs <- fmt.Errorf("%s: read back %d bytes that differ from the %d written", key, len(got), len(want))
+					return
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+}
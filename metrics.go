@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// metricsShutdownTimeout bounds how long the -metrics-addr server waits for
+// in-flight scrapes when the benchmark ends.
+const metricsShutdownTimeout = 5 * time.Second
+
+// opMetrics are the Prometheus series of one operation type. Workers update
+// them from updateStats and recordError alongside their own statistics.
+type opMetrics struct {
+	latency prometheus.Observer
+	ops     prometheus.Counter
+	errors  prometheus.Counter
+}
+
+// benchMetrics holds the series exposed on -metrics-addr. Tags set with -tag
+// become constant labels of every series.
+type benchMetrics struct {
+	registry *prometheus.Registry
+	latency  *prometheus.HistogramVec
+	ops      *prometheus.CounterVec
+	errors   *prometheus.CounterVec
+	running  prometheus.Gauge
+}
+
+func newBenchMetrics() (*benchMetrics, error) {
+	labels := prometheus.Labels(tags)
+	m := &benchMetrics{
+		registry: prometheus.NewRegistry(),
+		latency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:        "redis_benchmark_latency_seconds",
+			Help:        "Latency of successful benchmark operations.",
+			Buckets:     prometheus.ExponentialBuckets(0.0001, 2, 16),
+			ConstLabels: labels,
+		}, []string{"op"}),
+		ops: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name:        "redis_benchmark_operations_total",
+			Help:        "Benchmark operations completed successfully.",
+			ConstLabels: labels,
+		}, []string{"op"}),
+		errors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name:        "redis_benchmark_errors_total",
+			Help:        "Benchmark operations that failed.",
+			ConstLabels: labels,
+		}, []string{"op"}),
+		running: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name:        "redis_benchmark_running",
+			Help:        "1 while a measured run is in progress, 0 between runs.",
+			ConstLabels: labels,
+		}),
+	}
+	for _, c := range []prometheus.Collector{m.latency, m.ops, m.errors, m.running} {
+		if err := m.registry.Register(c); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+// attach makes the per-worker statistics of a measured run report to the
+// metrics. Warmup runs are not attached.
+func (m *benchMetrics) attach(workers []*runStats) {
+	for _, ws := range workers {
+		for name, s := range map[string]*operationStats{
+			"set": &ws.set, "get": &ws.get, "del": &ws.del,
+			"incr": &ws.incr, "hset": &ws.hset, "lpush": &ws.lpush, "blpop": &ws.blpop,
+			"lmpop": &ws.lmpop, "zmpop": &ws.zmpop,
+			"pipeline": &ws.hybridBatch, "command_pipeline": &ws.pipeline,
+			"command": &ws.command, "publish": &ws.publish, "deliver": &ws.deliver,
+			"scan": &ws.scan, "scan_iteration": &ws.scanIteration,
+		} {
+			s.metrics = &opMetrics{
+				latency: m.latency.WithLabelValues(name),
+				ops:     m.ops.WithLabelValues(name),
+				errors:  m.errors.WithLabelValues(name),
+			}
+		}
+	}
+}
+
+// serve exposes /metrics on addr. The returned function shuts the server
+// down, waiting up to metricsShutdownTimeout for scrapes in progress.
+func (m *benchMetrics) serve(addr string) (func(), error) {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{}))
+	srv := &http.Server{Handler: mux}
+	go func() {
+		if err := srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.Error("Metrics server failed", "error", err)
+		}
+	}()
+	return func() {
+		ctx, cancel := context.WithTimeout(context.Background(), metricsShutdownTimeout)
+		defer cancel()
+		if err := srv.Shutdown(ctx); err != nil {
+			logger.Warn("Failed to shut down metrics server", "error", err)
+		}
+	}, nil
+}
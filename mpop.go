@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// mpopKeys returns the list or sorted set keys ("list" or "zset") that
+// LMPOP and ZMPOP pop from.
+func mpopKeys(kind string) []string {
+	keys := make([]string, mpopKeyCount)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("%s%s_%d", keyPrefix, kind, i)
+	}
+	return keys
+}
+
+// preloadMPopKeys fills every list and sorted set used by LMPOP/ZMPOP with n
+// elements so the pops have something to return.
+func preloadMPopKeys(ctx context.Context, rdb benchClient, n int) error {
+	pipe := rdb.Pipeline()
+	for _, key := range mpopKeys("list") {
+		for i := 0; i < n; i++ {
+			pipe.RPush(ctx, key, i)
+		}
+	}
+	for _, key := range mpopKeys("zset") {
+		for i := 0; i < n; i++ {
+			pipe.ZAdd(ctx, key, &redis.Z{Score: float64(i), Member: i})
+		}
+	}
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// mpop issues LMPOP or ZMPOP across keys and records its latency and which
+// key the element came from.
+func mpop(ctx context.Context, client benchClient, command string, keys []string, direction string, stats *operationStats, run *benchmarkRun) {
+	args := []interface{}{command, len(keys)}
+	for _, k := range keys {
+		args = append(args, k)
+	}
+	args = append(args, direction)
+
+	start := time.Now()
+	reply, err := client.Do(ctx, args...).Slice()
+	if err != nil && err != redis.Nil {
+		recordError(stats)
+		return
+	}
+	updateStats(stats, time.Since(start).Seconds()*1000)
+
+	run.popMu.Lock()
+	defer run.popMu.Unlock()
+	if err == redis.Nil || len(reply) == 0 {
+		run.emptyPops[command]++
+		return
+	}
+	if key, ok := reply[0].(string); ok {
+		run.popCounts[key]++
+	}
+}
+
+// printPopCounts prints how many elements were popped from each key.
+func printPopCounts(command string, keys []string, counts map[string]int, empty int) {
+	sort.Strings(keys)
+	fmt.Printf("%s pops per key:", command)
+	for _, k := range keys {
+		fmt.Printf(" %s=%d", k, counts[k])
+	}
+	fmt.Printf(", empty=%d\n", empty)
+}
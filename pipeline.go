@@ -0,0 +1,116 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// loadPipelineFile reads a pipeline template: one command per line, with
+// arguments separated by whitespace. Blank lines and lines starting with #
+// are ignored. Arguments may contain the placeholders {key}, {value} and
+// {rand}, which are filled in each time the pipeline is executed.
+func loadPipelineFile(path string) ([][]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var commands [][]string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		commands = append(commands, strings.Fields(line))
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(commands) == 0 {
+		return nil, fmt.Errorf("no commands in %s", path)
+	}
+	return commands, nil
+}
+
+// expandCommand substitutes the placeholders of a template command.
+func expandCommand(tokens []string, key, value string, n int) []interface{} {
+	args := make([]interface{}, len(tokens))
+	for i, t := range tokens {
+		t = strings.ReplaceAll(t, "{key}", key)
+		t = strings.ReplaceAll(t, "{value}", value)
+		t = strings.ReplaceAll(t, "{rand}", strconv.Itoa(n))
+		args[i] = t
+	}
+	return args
+}
+
+// pipelineWorker repeatedly sends the whole pipeline template as a single
+// pipeline and records the latency of each Exec. One key, value and random
+// number are drawn per execution and shared by all commands in it.
+func pipelineWorker(
+	ctx context.Context,
+	workerID int,
+	client benchClient,
+	keys []string,
+	commands [][]string,
+	run *benchmarkRun,
+	stop <-chan struct{},
+	wg *sync.WaitGroup,
+) {
+	defer wg.Done()
+
+	stats := run.workerStats[workerID-1]
+	counters := &run.counters[workerID-1]
+	rng := workerRand(workerID)
+	picker := workerKeyPicker(rng, workerID, len(run.counters), len(keys))
+	for {
+		select {
+		case <-stop:
+			return
+		default:
+			if run.claim(1) == 0 {
+				return
+			}
+			key := keys[picker.next()]
+			value := generateValue(rng, workerID, valueSize.get("pipeline"))
+			n := rng.Int()
+
+			pipe := client.Pipeline()
+			for _, tokens := range commands {
+				pipe.Do(ctx, expandCommand(tokens, key, value, n)...)
+			}
+			start := time.Now()
+			// Exec only returns the first error, which may be the redis.Nil
+			// of a GET ahead of a failed command
+			cmds, _ := pipe.Exec(ctx)
+			if pipelineFailed(cmds) {
+				recordError(&stats.pipeline)
+				continue
+			}
+			updateStats(&stats.pipeline, time.Since(start).Seconds()*1000)
+			atomic.AddInt64(&counters.pipeline, 1)
+		}
+	}
+}
+
+// pipelineFailed reports whether any command of an executed pipeline
+// failed. A missing key is not a failure.
+func pipelineFailed(cmds []redis.Cmder) bool {
+	for _, cmd := range cmds {
+		if err := cmd.Err(); err != nil && err != redis.Nil {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"text/template"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// dedicatedClients opens a single-connection client for each of n workers,
+// with the hooks of shared. Every connection is established before it is
+// returned, so the measured run does not pay for dialing. With
+// -reconnect-on-error the clients replace their connection after the
+// chosen errors. close releases them all.
+func dedicatedClients(ctx context.Context, opts *redis.Options, shared opClients, n int) (clients []opClients, close func(), err error) {
+	o := *opts
+	o.PoolSize = 1
+	o.MinIdleConns = 1
+
+	close = func() {
+		for _, c := range clients {
+			c.set.Close()
+		}
+	}
+	for i := 0; i < n; i++ {
+		var c benchClient
+		if reconnectErrors != nil {
+			c = newReconnectingClient(&o)
+		} else {
+			c = newBenchClient(&o)
+		}
+		clients = append(clients, opClients{set: c, get: c, del: c})
+		if err := c.Ping(ctx).Err(); err != nil {
+			close()
+			return nil, nil, fmt.Errorf("worker %d: %w", i+1, err)
+		}
+		for _, h := range shared.hooks {
+			c.AddHook(h)
+		}
+	}
+	return clients, close, nil
+}
+
+// runPoolingComparison runs the workload once with all workers sharing the
+// connection pool and once with a dedicated connection per worker, and
+// compares throughput and latency of the two.
+func runPoolingComparison(ctx context.Context, ratios opRatios, opts *redis.Options, clients opClients, keys []string, tmpl *template.Template) {
+	fmt.Printf("\nPooling phase 1/2: %d workers sharing one connection pool\n", numClients)
+	pooled := newBenchmarkRun(ratios, numClients)
+	pooled.execute(ctx, clients, keys, testDuration)
+	pooled.printSummary(clients, tmpl)
+	sendResults(pooled)
+	if pooled.interrupted {
+		return
+	}
+	pooledConns := clients.set.PoolStats().TotalConns
+
+	fmt.Printf("\nPooling phase 2/2: %d workers with a dedicated connection each\n", numClients)
+	workers, closeWorkers, err := dedicatedClients(ctx, opts, clients, numClients)
+	if err != nil {
+		logger.Error("Failed to open dedicated connections, skipping phase 2", "error", err)
+		return
+	}
+	defer closeWorkers()
+	dedicated := newBenchmarkRun(ratios, numClients)
+	dedicated.workerClients = workers
+	dedicated.execute(ctx, clients, keys, testDuration)
+	dedicated.printSummary(clients, tmpl)
+	sendResults(dedicated)
+	if dedicated.interrupted {
+		return
+	}
+
+	ra, rb := pooled.report(), dedicated.report()
+	printReportComparison("Pooling comparison (B = dedicated vs A = shared pool):", ra, rb)
+	opsA := float64(pooled.completed()) / pooled.duration.Seconds()
+	opsB := float64(dedicated.completed()) / dedicated.duration.Seconds()
+	fmt.Printf("%-10s %14.2f %14.2f %9s\n", "Total", opsA, opsB, percentChange(opsA, opsB))
+	fmt.Printf("Connections: shared pool opened %d, dedicated mode %d\n", pooledConns, numClients)
+}
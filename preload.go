@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// preloadBatch is how many SETs each preload worker sends per pipeline.
+const preloadBatch = 100
+
+// preloadKeys writes every key once before the run, so GET and DEL find
+// existing data from the first operation. The keys are split among
+// numClients workers, each writing its share in pipelined batches with
+// values of the configured SET size and TTL. With -dbs every database is
+// preloaded in turn.
+func preloadKeys(ctx context.Context, clients opClients, keys []string) (time.Duration, error) {
+	start := time.Now()
+	targets := []benchClient{clients.set}
+	if clients.dbs != nil {
+		targets = targets[:0]
+		for _, c := range clients.dbs {
+			targets = append(targets, c.set)
+		}
+	}
+	workers := min(numClients, len(keys))
+	per := (len(keys) + workers - 1) / workers
+
+	for _, client := range targets {
+		var wg sync.WaitGroup
+		errs := make([]error, workers)
+		for w := 0; w < workers; w++ {
+			first, last := w*per, min((w+1)*per, len(keys))
+			wg.Add(1)
+			go func(w, first, last int) {
+				defer wg.Done()
+				errs[w] = preloadRange(ctx, client, keys, first, last, w+1)
+			}(w, first, last)
+		}
+		wg.Wait()
+		for _, err := range errs {
+			if err != nil {
+				return 0, err
+			}
+		}
+	}
+	return time.Since(start), nil
+}
+
+// preloadRange writes keys[first:last] on behalf of worker workerID.
+func preloadRange(ctx context.Context, client benchClient, keys []string, first, last, workerID int) error {
+	rng := workerRand(workerID)
+	ttls := make([]time.Duration, preloadBatch)
+	for i := first; i < last; i += preloadBatch {
+		end := min(i+preloadBatch, last)
+		pipe := client.Pipeline()
+		for j := i; j < end; j++ {
+			value := []byte(generateValue(rng, workerID, randomValueSize(rng, valueSize.get("set"), valueSizeMax)))
+			ttls[j-i] = setTTL(rng)
+			if checksums != nil {
+				checksums.writing(keys[j], value)
+			}
+			pipe.Set(ctx, keys[j], value, ttls[j-i])
+		}
+		if _, err := pipe.Exec(ctx); err != nil {
+			return fmt.Errorf("preloading %s: %w", keys[i], err)
+		}
+		if tracker != nil {
+			for j := i; j < end; j++ {
+				tracker.set(j, ttls[j-i])
+			}
+		}
+	}
+	return nil
+}
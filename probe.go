@@ -0,0 +1,54 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"sync"
+	"time"
+)
+
+const probeDialTimeout = 5 * time.Second
+
+// runTCPProbe repeatedly dials addr without speaking the Redis protocol and
+// reports connect latency and failure rate. It is useful for telling network
+// problems apart from Redis problems.
+func runTCPProbe(addr string, clients int, duration time.Duration) {
+	fmt.Printf("Starting TCP connect probe against %s...\n", addr)
+
+	var wg sync.WaitGroup
+	stats := operationStats{minTime: math.MaxFloat64}
+
+	deadline := time.Now().Add(duration)
+	for i := 0; i < clients; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for time.Now().Before(deadline) {
+				start := time.Now()
+				conn, err := net.DialTimeout(redisNetwork, addr, probeDialTimeout)
+				if err != nil {
+					recordError(&stats)
+					continue
+				}
+				updateStats(&stats, time.Since(start).Seconds()*1000)
+				conn.Close()
+			}
+		}()
+	}
+	wg.Wait()
+
+	failures := stats.errors
+	attempts := stats.count + failures
+	fmt.Println("\nTCP connect probe complete.")
+	fmt.Printf("Total clients: %d\n", clients)
+	fmt.Printf("Total time: %v\n", duration)
+	fmt.Printf("Connect attempts: %d\n", attempts)
+	failureRate := 0.0
+	if attempts > 0 {
+		failureRate = float64(failures) / float64(attempts) * 100
+	}
+	fmt.Printf("Connect failures: %d (%.2f%%)\n", failures, failureRate)
+	fmt.Printf("Average connects/sec: %.2f\n", float64(stats.count)/duration.Seconds())
+	printStats("Connect", &stats)
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Benchmark modes: kv runs the key-value workloads, pubsub publishes
+// messages to channels that the subscriber workers receive.
+const (
+	modeKV     = "kv"
+	modePubSub = "pubsub"
+)
+
+// pubsubChannels returns the -channels channel names.
+func pubsubChannels() []string {
+	channels := make([]string, numChannels)
+	for i := range channels {
+		channels[i] = keyPrefix + "channel:" + strconv.Itoa(i)
+	}
+	return channels
+}
+
+// pubsubWorker runs one side of -mode pubsub: the first numSubscribers
+// workers subscribe to every channel, the others PUBLISH to a random one.
+// Each message starts with the time it was published, so subscribers can
+// measure how long delivery took.
+func pubsubWorker(
+	ctx context.Context,
+	workerID int,
+	client benchClient,
+	run *benchmarkRun,
+	stop <-chan struct{},
+	wg *sync.WaitGroup,
+) {
+	defer wg.Done()
+
+	stats := run.workerStats[workerID-1]
+	counters := &run.counters[workerID-1]
+	channels := pubsubChannels()
+	runCtx, cancel := stopContext(ctx, stop)
+	defer cancel()
+
+	if workerID <= numSubscribers {
+		sub := client.Subscribe(ctx, channels...)
+		// A blocked receive does not watch its context, so closing the
+		// subscription is what ends it.
+		go func() {
+			<-runCtx.Done()
+			sub.Close()
+		}()
+		for {
+			msg, err := sub.ReceiveMessage(runCtx)
+			if err != nil {
+				if runCtx.Err() != nil {
+					return
+				}
+				recordError(&stats.deliver)
+				continue
+			}
+			sent, _, _ := strings.Cut(msg.Payload, ":")
+			ns, err := strconv.ParseInt(sent, 10, 64)
+			if err != nil {
+				recordError(&stats.deliver)
+				continue
+			}
+			updateStats(&stats.deliver, float64(time.Now().UnixNano()-ns)/1e6)
+			atomic.AddInt64(&counters.received, 1)
+		}
+	}
+
+	rng := workerRand(workerID)
+	for {
+		select {
+		case <-stop:
+			return
+		default:
+		}
+		if run.pace(runCtx, 1) == 0 {
+			return
+		}
+		channel := channels[rng.Intn(len(channels))]
+		value := generateValue(rng, workerID, valueSize.get("publish"))
+		start := time.Now()
+		msg := strconv.FormatInt(start.UnixNano(), 10) + ":" + value
+		if err := client.Publish(ctx, channel, msg).Err(); err == nil {
+			updateStats(&stats.publish, time.Since(start).Seconds()*1000)
+			atomic.AddInt64(&counters.published, 1)
+		} else {
+			recordError(&stats.publish)
+		}
+	}
+}
+
+// printPubSubSummary prints the publisher and subscriber results of a
+// -mode pubsub run. Every subscriber listens on every channel, so each
+// published message should be received once per subscriber.
+func (r *benchmarkRun) printPubSubSummary() {
+	subscribers := numSubscribers
+	publishers := len(r.counters) - subscribers
+	published, received := r.stats.publish.count, r.stats.deliver.count
+	fmt.Printf("Pub/sub: %d publishers, %d subscribers, %d channels\n", publishers, subscribers, numChannels)
+	fmt.Printf("Messages published: %d (%.2f msgs/sec)\n", published, float64(published)/r.duration.Seconds())
+
+	expected := published * subscribers
+	receivedPct := 0.0
+	if expected > 0 {
+		receivedPct = float64(received) / float64(expected) * 100
+	}
+	fmt.Printf("Messages received: %d of %d expected (%.2f%%, %.2f msgs/sec)\n",
+		received, expected, receivedPct, float64(received)/r.duration.Seconds())
+	r.printErrors()
+	printStats("PUBLISH", &r.stats.publish)
+	printStats("Delivery", &r.stats.deliver)
+	printThroughput(r.throughputReport())
+	printWorkerTable(r.workerReports(r.duration))
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// queueKey is the list that producers LPUSH to and consumers BLPOP from.
+func queueKey() string {
+	return keyPrefix + "queue"
+}
+
+// queueWorker runs one side of the -queue-consumers workload: the first
+// queueConsumers workers block on BLPOP, the others LPUSH items. A consumer
+// wait ends either when an item arrives or when -queue-timeout fires.
+func queueWorker(
+	ctx context.Context,
+	workerID int,
+	client benchClient,
+	run *benchmarkRun,
+	stop <-chan struct{},
+	wg *sync.WaitGroup,
+) {
+	defer wg.Done()
+
+	stats := run.workerStats[workerID-1]
+	counters := &run.counters[workerID-1]
+	consumer := workerID <= queueConsumers
+	key := queueKey()
+	rng := workerRand(workerID)
+	for {
+		select {
+		case <-stop:
+			return
+		default:
+		}
+
+		if !consumer {
+			if run.claim(1) == 0 {
+				return
+			}
+			value := generateValue(rng, workerID, valueSize.get("lpush"))
+			start := time.Now()
+			if err := client.LPush(ctx, key, value).Err(); err == nil {
+				updateStats(&stats.lpush, time.Since(start).Seconds()*1000)
+				atomic.AddInt64(&counters.lpush, 1)
+			} else {
+				recordError(&stats.lpush)
+			}
+			continue
+		}
+
+		start := time.Now()
+		_, err := client.BLPop(ctx, queueTimeout, key).Result()
+		wait := time.Since(start).Seconds() * 1000
+		switch err {
+		case nil:
+			updateStats(&stats.blpop, wait)
+			atomic.AddInt64(&counters.blpop, 1)
+		case redis.Nil:
+			select {
+			case <-stop:
+				// The wait outlived the run; producers had already stopped.
+				return
+			default:
+			}
+			atomic.AddInt64(&counters.queueTimeouts, 1)
+		default:
+			recordError(&stats.blpop)
+		}
+	}
+}
+
+// printQueueSummary prints the producer and consumer results of a
+// -queue-consumers run.
+func (r *benchmarkRun) printQueueSummary() {
+	consumers := queueConsumers
+	producers := len(r.counters) - consumers
+	pushed, popped := r.stats.lpush.count, r.stats.blpop.count
+	fmt.Printf("Queue %q: %d producers (LPUSH), %d consumers (BLPOP, timeout %v)\n",
+		queueKey(), producers, consumers, queueTimeout)
+	fmt.Printf("LPUSH operations: %d (%.2f ops/sec)\n", pushed, float64(pushed)/r.duration.Seconds())
+	fmt.Printf("BLPOP items received: %d (%.2f ops/sec)\n", popped, float64(popped)/r.duration.Seconds())
+
+	timeoutPct := 0.0
+	if waits := popped + r.queueTimeouts; waits > 0 {
+		timeoutPct = float64(r.queueTimeouts) / float64(waits) * 100
+	}
+	fmt.Printf("BLPOP timeouts: %d (%.2f%% of waits)\n", r.queueTimeouts, timeoutPct)
+	printStats("LPUSH", &r.stats.lpush)
+	printStats("BLPOP wait", &r.stats.blpop)
+	printThroughput(r.throughputReport())
+	printWorkerTable(r.workerReports(r.duration))
+	r.printDBTotals()
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// rampDownStep is how often -ramp-down lowers the rate.
+const rampDownStep = 100 * time.Millisecond
+
+// rampPhase is the result of one -ramp phase.
+type rampPhase struct {
+	clients   int
+	opsPerSec float64
+	p99       float64
+}
+
+// runRamp runs the workload in phases from -ramp-start to -ramp-max clients,
+// adding -ramp-step clients per phase, and prints throughput and p99
+// latency per client count so the point where Redis stops scaling shows.
+// Every phase starts with fresh statistics.
+func runRamp(ctx context.Context, ratios opRatios, clients opClients, keys []string) {
+	fmt.Printf("Ramping from %d to %d clients in steps of %d, %v per step\n",
+		rampStart, rampMax, rampStep, rampStepDuration)
+	fmt.Printf("%8s %14s %10s\n", "Clients", "Ops/sec", "P99 (ms)")
+
+	var phases []rampPhase
+	for n := rampStart; n <= rampMax; n += rampStep {
+		run := newBenchmarkRun(ratios, n)
+		started := time.Now()
+		stop := run.start(ctx, clients, keys, false)
+		select {
+		case <-time.After(rampStepDuration):
+		case sig := <-signals:
+			releaseSignals()
+			run.interrupted = true
+			fmt.Printf("Received %v, stopping the ramp\n", sig)
+		}
+		stop()
+		run.duration = time.Since(started)
+
+		var all latencyHistogram
+		for _, op := range run.errorOps() {
+			all.merge(&op.stats.hist)
+		}
+		phase := rampPhase{
+			clients:   n,
+			opsPerSec: float64(run.completed()) / run.duration.Seconds(),
+			p99:       all.percentile(99),
+		}
+		phases = append(phases, phase)
+		fmt.Printf("%8d %14.2f %10.2f\n", phase.clients, phase.opsPerSec, phase.p99)
+		if run.interrupted {
+			break
+		}
+	}
+
+	peak := phases[0]
+	for _, p := range phases[1:] {
+		if p.opsPerSec > peak.opsPerSec {
+			peak = p
+		}
+	}
+	fmt.Printf("Peak throughput: %.2f ops/sec with %d clients (p99 %.2f ms)\n", peak.opsPerSec, peak.clients, peak.p99)
+}
+
+// rampDown keeps the workload of r running for -ramp-down once its measured
+// window is over, lowering the rate linearly from -rate towards zero, so the
+// run ends without a load cliff. The operations go to a run of their own,
+// like a -ramp phase, and stay out of the statistics of r.
+func (r *benchmarkRun) rampDown(ctx context.Context, clients opClients, keys []string) {
+	down := newBenchmarkRun(r.ratios, len(r.counters))
+	down.valueSize, down.valueSizeMax, down.intValues = r.valueSize, r.valueSizeMax, r.intValues
+	down.workerClients = r.workerClients
+	down.pacer = nil // The limiter's rate is the one lowered
+
+	started := time.Now()
+	stop := down.start(ctx, clients, keys, false)
+	ticker := time.NewTicker(rampDownStep)
+	defer ticker.Stop()
+loop:
+	for {
+		select {
+		case <-ticker.C:
+			left := rampDownDuration - time.Since(started)
+			if left <= 0 {
+				break loop
+			}
+			down.limiter.SetLimit(rate.Limit(targetRate * left.Seconds() / rampDownDuration.Seconds()))
+		case sig := <-signals:
+			releaseSignals()
+			fmt.Printf("\nReceived %v, stopping the ramp-down\n", sig)
+			break loop
+		}
+	}
+	stop()
+	r.rampDownTime = time.Since(started)
+	r.rampDownOps = down.completed()
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"math"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"golang.org/x/time/rate"
+)
+
+const (
+	// rateEstimateOps is the number of sequential SET, GET and DEL commands
+	// each that estimateClients times.
+	rateEstimateOps = 100
+
+	// rateClientHeadroom scales the client estimate of -rate, as the
+	// latency under load exceeds the unloaded latency it is based on.
+	rateClientHeadroom = 2
+)
+
+// newRateLimiter returns the limiter shared by the workers of a run to
+// hold -rate, or nil when the rate is unbounded. The burst of one
+// operation per client lets workers that fell behind for a moment catch up
+// instead of losing the tokens, and covers one pipeline, so a -pipeline or
+// hybrid batch can wait for all its operations at once.
+func newRateLimiter() *rate.Limiter {
+	if targetRate <= 0 {
+		return nil
+	}
+	return rate.NewLimiter(rate.Limit(targetRate), max(numClients, pipelineDepth, hybridDepth))
+}
+
+// pace claims up to n operations from the -requests budget and waits until
+// the run's limiter or pacer allows them. It returns how many operations
+// the caller may issue: n, fewer at the end of the budget, or 0 once the
+// budget is spent or the run stops. The wait happens before an operation's
+// timer starts, so it is not part of the measured latency.
+func (r *benchmarkRun) pace(ctx context.Context, n int) int {
+	if n = r.claim(n); n == 0 {
+		return 0
+	}
+	if r.pacer != nil {
+		if !r.pacer.wait(ctx, n) {
+			return 0
+		}
+	} else if r.limiter != nil && r.limiter.WaitN(ctx, n) != nil {
+		return 0
+	}
+	return n
+}
+
+// rateSchedule paces -jitter-free-scheduler runs. Operation k of the run is
+// due at start + k*interval, and a worker sleeps until the due time of the
+// operations it claims. The due times are absolute, so an operation sent
+// late does not delay the ones after it and the rate does not drift; the
+// lag behind the due times is recorded instead.
+type rateSchedule struct {
+	start    time.Time
+	interval float64 // Nanoseconds between operations
+	next     atomic.Int64
+
+	// Lag of the dispatches behind their due times, in nanoseconds
+	lagSum, lagMax, lastLag atomic.Int64
+	dispatched              atomic.Int64
+}
+
+// begin starts the schedule at the current time.
+func (s *rateSchedule) begin() {
+	s.start = time.Now()
+}
+
+// wait claims the next n operations of the schedule and sleeps until the
+// first of them is due. It returns false when ctx ends first.
+func (s *rateSchedule) wait(ctx context.Context, n int) bool {
+	k := s.next.Add(int64(n)) - int64(n)
+	due := s.start.Add(time.Duration(float64(k) * s.interval))
+	if d := time.Until(due); d > 0 {
+		timer := time.NewTimer(d)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return false
+		}
+	}
+	lag := int64(time.Since(due))
+	s.lagSum.Add(lag)
+	s.dispatched.Add(1)
+	s.lastLag.Store(lag)
+	for {
+		old := s.lagMax.Load()
+		if lag <= old || s.lagMax.CompareAndSwap(old, lag) {
+			break
+		}
+	}
+	return true
+}
+
+// flagGiven reports whether the named flag was set on the command line or
+// by -config.
+func flagGiven(name string) bool {
+	given := false
+	flag.Visit(func(f *flag.Flag) {
+		given = given || f.Name == name
+	})
+	return given
+}
+
+// estimateClients derives the number of clients needed to hold -rate from
+// Little's Law: the operations in flight are the rate times their latency,
+// and each client has one in flight. The latency is the mean unloaded p50
+// of SET, GET and DEL, timed on a connection of its own, with
+// rateClientHeadroom for the load. It also returns that latency.
+func estimateClients(ctx context.Context, opts *redis.Options) (int, float64, error) {
+	probeOpts := *opts
+	probeOpts.OnConnect = nil
+	probeOpts.PoolSize = 1
+	probeOpts.MinIdleConns = 0
+	probe := newBenchClient(&probeOpts)
+	defer probe.Close()
+
+	floors, err := calibrateLatencyFloor(ctx, probe, rateEstimateOps)
+	if err != nil {
+		return 0, 0, err
+	}
+	var latency float64
+	for _, f := range floors {
+		latency += f.p50
+	}
+	latency /= float64(len(floors))
+	clients := int(math.Ceil(targetRate * latency / 1000 * rateClientHeadroom))
+	return max(clients, 1), latency, nil
+}
+
+// stopContext returns a context that is cancelled when stop is closed, so
+// workers waiting on the limiter return as soon as the run ends.
+func stopContext(ctx context.Context, stop <-chan struct{}) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(ctx)
+	go func() {
+		select {
+		case <-stop:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	return ctx, cancel
+}
+
+// RateReport compares the achieved throughput of a -rate run with the
+// target. The lag figures are only known with -jitter-free-scheduler: how
+// far the dispatches fell behind their due times on average and at most,
+// and the drift of the last dispatch.
+type RateReport struct {
+	TargetOpsPerSec   float64 `json:"target_ops_per_sec"`
+	AchievedOpsPerSec float64 `json:"achieved_ops_per_sec"`
+	Scheduler         string  `json:"scheduler"`
+	MeanLagMs         float64 `json:"mean_lag_ms,omitempty"`
+	MaxLagMs          float64 `json:"max_lag_ms,omitempty"`
+	DriftMs           float64 `json:"drift_ms,omitempty"`
+}
+
+// rateReport returns the rate statistics of the run, or nil when it ran
+// unbounded.
+func (r *benchmarkRun) rateReport() *RateReport {
+	if r.limiter == nil {
+		return nil
+	}
+	report := &RateReport{
+		TargetOpsPerSec:   targetRate,
+		AchievedOpsPerSec: float64(r.completed()) / r.duration.Seconds(),
+		Scheduler:         "token-bucket",
+	}
+	if p := r.pacer; p != nil {
+		report.Scheduler = "absolute"
+		if n := p.dispatched.Load(); n > 0 {
+			report.MeanLagMs = float64(p.lagSum.Load()) / float64(n) / 1e6
+			report.MaxLagMs = float64(p.lagMax.Load()) / 1e6
+			report.DriftMs = float64(p.lastLag.Load()) / 1e6
+		}
+	}
+	return report
+}
+
+// printRate compares the achieved throughput to -rate.
+func printRate(report *RateReport) {
+	fmt.Printf("Rate limit: target=%.2f ops/sec, achieved=%.2f ops/sec (%.1f%%)\n",
+		report.TargetOpsPerSec, report.AchievedOpsPerSec, report.AchievedOpsPerSec/report.TargetOpsPerSec*100)
+	if report.Scheduler == "absolute" {
+		fmt.Printf("Dispatch lag behind schedule (ms): Mean=%.3f, Max=%.3f; drift at the end=%.3f\n",
+			report.MeanLagMs, report.MaxLagMs, report.DriftMs)
+	}
+}
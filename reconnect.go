@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// parseReconnectErrors parses -reconnect-on-error: "all", or a
+// comma-separated list of error reply prefixes such as READONLY,LOADING.
+func parseReconnectErrors(spec string) ([]string, error) {
+	var prefixes []string
+	for _, p := range strings.Split(spec, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			prefixes = append(prefixes, p)
+		}
+	}
+	if len(prefixes) == 0 {
+		return nil, fmt.Errorf("expected all or a comma-separated list of error prefixes")
+	}
+	return prefixes, nil
+}
+
+// reconnectOn reports whether a command that failed with err makes its
+// worker replace its connection under -reconnect-on-error. A missing key
+// is not a failure, and neither is the end of the run.
+func reconnectOn(err error) bool {
+	if err == nil || err == redis.Nil || errors.Is(err, context.Canceled) {
+		return false
+	}
+	for _, p := range reconnectErrors {
+		if p == "all" || strings.HasPrefix(err.Error(), p) {
+			return true
+		}
+	}
+	return false
+}
+
+// reconnectingClient is a dedicated worker client that closes its
+// connection and opens a new one after a command fails with one of the
+// -reconnect-on-error errors. Without it the connection is reused, unless
+// go-redis discards it as broken, as it does after network errors and
+// timeouts. The replacement dials with the next command, so its cost is
+// part of the recovery. Only the worker that owns the client uses it, so
+// swapping the client needs no lock.
+type reconnectingClient struct {
+	benchClient
+	opts  *redis.Options // For the replacements, which dial on demand
+	hooks []redis.Hook
+}
+
+func newReconnectingClient(opts *redis.Options) *reconnectingClient {
+	c := &reconnectingClient{}
+	c.benchClient = c.open(opts)
+	o := *opts
+	o.MinIdleConns = 0
+	c.opts = &o
+	return c
+}
+
+// open returns a new client with the hooks of c. The reconnect hook is
+// added first, so it runs after the others have seen the failed command.
+func (c *reconnectingClient) open(opts *redis.Options) benchClient {
+	client := newBenchClient(opts)
+	client.AddHook(reconnectHook{c})
+	for _, h := range c.hooks {
+		client.AddHook(h)
+	}
+	return client
+}
+
+func (c *reconnectingClient) AddHook(h redis.Hook) {
+	c.hooks = append(c.hooks, h)
+	c.benchClient.AddHook(h)
+}
+
+func (c *reconnectingClient) reconnect() {
+	old := c.benchClient
+	c.benchClient = c.open(c.opts)
+	old.Close()
+	if connErrors != nil {
+		connErrors.forcedReconnect()
+	}
+}
+
+// reconnectHook replaces the connection of its client after a failed
+// command or pipeline.
+type reconnectHook struct {
+	c *reconnectingClient
+}
+
+func (h reconnectHook) BeforeProcess(ctx context.Context, cmd redis.Cmder) (context.Context, error) {
+	return ctx, nil
+}
+
+func (h reconnectHook) AfterProcess(ctx context.Context, cmd redis.Cmder) error {
+	if reconnectOn(cmd.Err()) {
+		h.c.reconnect()
+	}
+	return nil
+}
+
+func (h reconnectHook) BeforeProcessPipeline(ctx context.Context, cmds []redis.Cmder) (context.Context, error) {
+	return ctx, nil
+}
+
+func (h reconnectHook) AfterProcessPipeline(ctx context.Context, cmds []redis.Cmder) error {
+	for _, cmd := range cmds {
+		if reconnectOn(cmd.Err()) {
+			h.c.reconnect()
+			break
+		}
+	}
+	return nil
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+)
+
+func TestReconnectingClient(t *testing.T) {
+	server := miniredis.RunT(t)
+	defer func(prefixes []string) { reconnectErrors = prefixes }(reconnectErrors)
+	reconnectErrors = []string{"WRONGTYPE"}
+
+	c := newReconnectingClient(&redis.Options{Addr: server.Addr(), PoolSize: 1})
+	defer c.Close()
+	ctx := context.Background()
+	if err := c.HSet(ctx, "hash", "field", "value").Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	first := c.benchClient
+	if err := c.Get(ctx, "missing").Err(); err != redis.Nil {
+		t.Fatalf("GET of a missing key: %v", err)
+	}
+	if c.benchClient != first {
+		t.Fatal("a missing key replaced the client")
+	}
+
+	if err := c.Get(ctx, "hash").Err(); err == nil {
+		t.Fatal("GET of a hash succeeded")
+	}
+	if c.benchClient == first {
+		t.Fatal("a WRONGTYPE error kept the client")
+	}
+	if err := c.HSet(ctx, "hash", "field", "value").Err(); err != nil {
+		t.Fatalf("command after the reconnect: %v", err)
+	}
+	if n := server.TotalConnectionCount(); n != 2 {
+		t.Errorf("connections opened = %d, want 2", n)
+	}
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"text/template"
+	"time"
+)
+
+// Report is the summary of a benchmark run. It is the data passed to
+// -report-template and the JSON body sent to -results-callback-url.
+type Report struct {
+	Clients         int               `json:"clients"`
+	Keys            int               `json:"keys"`
+	Duration        time.Duration     `json:"-"`
+	DurationSeconds float64           `json:"duration_sec"`
+	RampDownSeconds float64           `json:"ramp_down_sec,omitempty"`
+	Rate            *RateReport       `json:"rate,omitempty"`
+	SetBytes        int64             `json:"set_bytes"`
+	GetBytes        int64             `json:"get_bytes"`
+	SetMBPerSec     float64           `json:"set_mb_per_sec"`
+	GetMBPerSec     float64           `json:"get_mb_per_sec"`
+	Throughput      ThroughputReport  `json:"throughput"`
+	Operations      []OperationReport `json:"operations"`
+	Workers         []WorkerReport    `json:"workers"`
+	Tags            map[string]string `json:"tags,omitempty"`
+	ValueSizes      map[string]int    `json:"value_sizes,omitempty"`
+	SetValueSizeMax int               `json:"set_value_size_max,omitempty"`
+	SizeTiers       []SizeTierReport  `json:"size_tiers,omitempty"`
+	ConnErrors      *ConnErrorReport  `json:"connection_errors,omitempty"`
+	ErrorRate       float64           `json:"error_rate"`
+}
+
+// OperationReport holds the totals and latency statistics for one
+// operation type. Latencies are in milliseconds. Histogram is the encoded
+// latency histogram, so that merge can recompute the percentiles; they are
+// omitted when they are unknown.
+type OperationReport struct {
+	Name      string  `json:"name"`
+	Count     int     `json:"count"`
+	Errors    int     `json:"errors"`
+	OpsPerSec float64 `json:"ops_per_sec"`
+	MinMs     float64 `json:"min_ms"`
+	AvgMs     float64 `json:"avg_ms"`
+	MaxMs     float64 `json:"max_ms"`
+	P50Ms     float64 `json:"p50_ms,omitempty"`
+	P95Ms     float64 `json:"p95_ms,omitempty"`
+	P99Ms     float64 `json:"p99_ms,omitempty"`
+	P999Ms    float64 `json:"p999_ms,omitempty"`
+	Histogram string  `json:"histogram,omitempty"`
+}
+
+func newOperationReport(name string, stats *operationStats, duration time.Duration) OperationReport {
+	stats.mu.Lock()
+	defer stats.mu.Unlock()
+
+	op := OperationReport{
+		Name:      name,
+		Count:     stats.count,
+		Errors:    stats.errors,
+		OpsPerSec: float64(stats.count) / duration.Seconds(),
+	}
+	if stats.count > 0 {
+		op.MinMs = stats.minTime
+		op.AvgMs = stats.totalTime / float64(stats.count)
+		op.MaxMs = stats.maxTime
+		op.P50Ms = stats.hist.percentile(50)
+		op.P95Ms = stats.hist.percentile(95)
+		op.P99Ms = stats.hist.percentile(99)
+		op.P999Ms = stats.hist.percentile(99.9)
+		// An unencodable histogram only costs merge its percentiles
+		op.Histogram, _ = stats.hist.encode()
+	}
+	return op
+}
+
+// builtinTemplates can be selected by name with -report-template instead of
+// a template file path.
+var builtinTemplates = map[string]string{
+	"markdown": `# Redis Benchmark Report
+
+- Clients: {{.Clients}}
+- Keys: {{.Keys}}
+- Duration: {{.Duration}}
+- Bandwidth: SET {{printf "%.2f" .SetMBPerSec}} MB/s, GET {{printf "%.2f" .GetMBPerSec}} MB/s
+- Throughput per second: P5={{printf "%.0f" .Throughput.P5}}, P50={{printf "%.0f" .Throughput.P50}}, P95={{printf "%.0f" .Throughput.P95}} ops/sec
+
+| Operation | Count | Ops/sec | Min (ms) | Avg (ms) | P99 (ms) | Max (ms) |
+|-----------|-------|---------|----------|----------|----------|----------|
+{{range .Operations}}| {{.Name}} | {{.Count}} | {{printf "%.2f" .OpsPerSec}} | {{printf "%.2f" .MinMs}} | {{printf "%.2f" .AvgMs}} | {{printf "%.2f" .P99Ms}} | {{printf "%.2f" .MaxMs}} |
+{{end}}`,
+	"html": `<!DOCTYPE html>
+<html>
+<head><title>Redis Benchmark Report</title></head>
+<body>
+<h1>Redis Benchmark Report</h1>
+<p>Clients: {{.Clients}}, Keys: {{.Keys}}, Duration: {{.Duration}}</p>
+<table border="1">
+<tr><th>Operation</th><th>Count</th><th>Ops/sec</th><th>Min (ms)</th><th>Avg (ms)</th><th>P99 (ms)</th><th>Max (ms)</th></tr>
+{{range .Operations}}<tr><td>{{.Name}}</td><td>{{.Count}}</td><td>{{printf "%.2f" .OpsPerSec}}</td><td>{{printf "%.2f" .MinMs}}</td><td>{{printf "%.2f" .AvgMs}}</td><td>{{printf "%.2f" .P99Ms}}</td><td>{{printf "%.2f" .MaxMs}}</td></tr>
+{{end}}</table>
+</body>
+</html>
+`,
+}
+
+// loadReportTemplate parses a built-in template by name or a template file
+// by path, and checks it renders against an example report so mistakes
+// surface before the benchmark runs.
+func loadReportTemplate(nameOrPath string) (*template.Template, error) {
+	text, ok := builtinTemplates[nameOrPath]
+	if !ok {
+		data, err := os.ReadFile(nameOrPath)
+		if err != nil {
+			return nil, err
+		}
+		text = string(data)
+	}
+
+	tmpl, err := template.New("report").Parse(text)
+	if err != nil {
+		return nil, err
+	}
+
+	example := Report{Operations: []OperationReport{{Name: "SET"}}}
+	if err := tmpl.Execute(io.Discard, example); err != nil {
+		return nil, fmt.Errorf("template does not render: %w", err)
+	}
+	return tmpl, nil
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// requirement is one precondition from -require. Supported forms are
+//
+//	version>=7.0            server version from INFO server
+//	module=search           a module loaded according to MODULE LIST
+//	info:role=master        a field returned by INFO
+//	maxmemory-policy=allkeys-lru
+//	                        any other name is a CONFIG GET parameter
+type requirement struct {
+	spec         string
+	kind         string
+	name, want   string
+	major, minor int
+}
+
+// parseRequirements parses the comma-separated -require list.
+func parseRequirements(spec string) ([]requirement, error) {
+	var reqs []requirement
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		req := requirement{spec: item}
+		if v, ok := strings.CutPrefix(item, "version>="); ok {
+			majStr, minStr, _ := strings.Cut(v, ".")
+			if minStr == "" {
+				minStr = "0"
+			}
+			var err1, err2 error
+			req.kind = "version"
+			req.major, err1 = strconv.Atoi(majStr)
+			req.minor, err2 = strconv.Atoi(minStr)
+			if err1 != nil || err2 != nil {
+				return nil, fmt.Errorf("%q: expected version>=MAJOR.MINOR", item)
+			}
+			reqs = append(reqs, req)
+			continue
+		}
+
+		name, want, ok := strings.Cut(item, "=")
+		if !ok || name == "" || want == "" {
+			return nil, fmt.Errorf("%q: expected name=value or version>=MAJOR.MINOR", item)
+		}
+		req.want = want
+		switch {
+		case name == "module":
+			req.kind, req.name = "module", want
+		case strings.HasPrefix(name, "info:"):
+			req.kind, req.name = "info", strings.TrimPrefix(name, "info:")
+		default:
+			req.kind, req.name = "config", name
+		}
+		reqs = append(reqs, req)
+	}
+	return reqs, nil
+}
+
+// check reports what the server has for the requirement and whether it is
+// satisfied.
+func (r requirement) check(ctx context.Context, rdb benchClient) (string, bool, error) {
+	switch r.kind {
+	case "version":
+		version, err := serverVersion(ctx, rdb)
+		if err != nil {
+			return "", false, err
+		}
+		return version, versionAtLeast(version, r.major, r.minor), nil
+	case "module":
+		modules, err := loadedModules(ctx, rdb)
+		if err != nil {
+			return "", false, err
+		}
+		for _, m := range modules {
+			if strings.EqualFold(m, r.name) {
+				return strings.Join(modules, " "), true, nil
+			}
+		}
+		return strings.Join(modules, " "), false, nil
+	case "info":
+		info, err := rdb.Info(ctx, "all").Result()
+		if err != nil {
+			return "", false, err
+		}
+		got := parseInfo(info)[r.name]
+		return got, got == r.want, nil
+	default:
+		reply, err := rdb.ConfigGet(ctx, r.name).Result()
+		if err != nil {
+			return "", false, err
+		}
+		if len(reply) != 2 {
+			return "", false, fmt.Errorf("unknown config parameter %q", r.name)
+		}
+		got := fmt.Sprint(reply[1])
+		if r.name == "notify-keyspace-events" {
+			// Redis reorders the flags, so only require that each one is set.
+			for _, c := range r.want {
+				if !strings.ContainsRune(got, c) {
+					return got, false, nil
+				}
+			}
+			return got, true, nil
+		}
+		return got, got == r.want, nil
+	}
+}
+
+// loadedModules returns the names reported by MODULE LIST.
+func loadedModules(ctx context.Context, rdb benchClient) ([]string, error) {
+	reply, err := rdb.Do(ctx, "MODULE", "LIST").Slice()
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, entry := range reply {
+		fields, ok := entry.([]interface{})
+		if !ok {
+			continue
+		}
+		for i := 0; i+1 < len(fields); i += 2 {
+			if fmt.Sprint(fields[i]) == "name" {
+				names = append(names, fmt.Sprint(fields[i+1]))
+			}
+		}
+	}
+	return names, nil
+}
+
+// checkRequirements verifies every requirement, printing each result, and
+// returns an error naming the ones that are not met.
+func checkRequirements(ctx context.Context, rdb benchClient, reqs []requirement) error {
+	var failed []string
+	for _, req := range reqs {
+		got, ok, err := req.check(ctx, rdb)
+		switch {
+		case err != nil:
+			fmt.Printf("Requirement %s: FAILED (%v)\n", req.spec, err)
+			failed = append(failed, req.spec)
+		case !ok:
+			fmt.Printf("Requirement %s: FAILED (server has %q)\n", req.spec, got)
+			failed = append(failed, req.spec)
+		default:
+			fmt.Printf("Requirement %s: ok (server has %q)\n", req.spec, got)
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("unmet requirements: %s", strings.Join(failed, ", "))
+	}
+	return nil
+}
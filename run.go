@@ -0,0 +1,942 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"text/template"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// opRatios is the normalized operation mix used by the workers of a run.
+type opRatios struct {
+	set, get, del, idletime, freq, lmpop, zmpop float64
+	incr, hset, lpush                           float64
+}
+
+// sum returns the total of all ratios.
+func (r opRatios) sum() float64 {
+	return r.set + r.get + r.del + r.idletime + r.freq + r.lmpop + r.zmpop + r.incr + r.hset + r.lpush
+}
+
+// normalized scales the ratios so they add up to one.
+func (r opRatios) normalized() opRatios {
+	total := r.sum()
+	return opRatios{
+		set:      r.set / total,
+		get:      r.get / total,
+		del:      r.del / total,
+		idletime: r.idletime / total,
+		freq:     r.freq / total,
+		lmpop:    r.lmpop / total,
+		zmpop:    r.zmpop / total,
+		incr:     r.incr / total,
+		hset:     r.hset / total,
+		lpush:    r.lpush / total,
+	}
+}
+
+// parseRatios overrides the ratios in base with a comma-separated list such
+// as "set=0.2,get=0.8,del=0".
+func parseRatios(spec string, base opRatios) (opRatios, error) {
+	r := base
+	for _, part := range strings.Split(spec, ",") {
+		name, value, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			return r, fmt.Errorf("expected op=ratio, got %q", part)
+		}
+		v, err := strconv.ParseFloat(value, 64)
+		if err != nil || v < 0 {
+			return r, fmt.Errorf("invalid ratio for %s: %q", name, value)
+		}
+		switch name {
+		case "set":
+			r.set = v
+		case "get":
+			r.get = v
+		case "del":
+			r.del = v
+		case "idletime":
+			r.idletime = v
+		case "freq":
+			r.freq = v
+		case "lmpop":
+			r.lmpop = v
+		case "zmpop":
+			r.zmpop = v
+		case "incr":
+			r.incr = v
+		case "hset":
+			r.hset = v
+		case "lpush":
+			r.lpush = v
+		default:
+			return r, fmt.Errorf("unknown operation %q", name)
+		}
+	}
+	if r.sum() == 0 {
+		return r, fmt.Errorf("all ratios are zero")
+	}
+	return r, nil
+}
+
+// parsePhaseB parses -phase-b: ratio overrides as for parseRatios, and
+// optionally value-size=N for the SET values of phase B. The returned size
+// is 0 when the spec keeps the SET value size.
+func parsePhaseB(spec string, base opRatios) (opRatios, int, error) {
+	var ratioParts []string
+	size := 0
+	for _, part := range strings.Split(spec, ",") {
+		name, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+		if name != "value-size" {
+			ratioParts = append(ratioParts, part)
+			continue
+		}
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 {
+			return base, 0, fmt.Errorf("invalid value-size %q: expected a positive number of bytes", value)
+		}
+		size = n
+	}
+	if len(ratioParts) == 0 {
+		return base, size, nil
+	}
+	r, err := parseRatios(strings.Join(ratioParts, ","), base)
+	return r, size, err
+}
+
+// benchmarkRun holds the counters and statistics of a single timed run, so
+// several runs can be executed back to back within one invocation.
+type benchmarkRun struct {
+	ratios   opRatios
+	duration time.Duration
+	// interrupted is set when SIGINT or SIGTERM ended the run before
+	// its duration; duration is then the time it actually ran.
+	interrupted bool
+
+	// counters and workerStats are recorded by each worker without
+	// contention. Once the workers have stopped the counters are summed
+	// into the totals below and workerStats are merged into stats.
+	counters    []workerCounters
+	workerStats []*runStats
+	stats       *runStats
+	oom         oomGuard
+
+	totalSet, totalGet, totalDel int
+	totalSetData, totalGetData   int64
+	waitAOFAchieved              int
+
+	// Elements popped per list/zset key by LMPOP/ZMPOP, and pops that found
+	// every key empty.
+	popMu     sync.Mutex
+	popCounts map[string]int
+	emptyPops map[string]int
+
+	// GETs that found their key, for reporting -keyspace-hit-ratio.
+	getHits int
+
+	// BLPOP waits that ended with -queue-timeout instead of an item.
+	queueTimeouts int
+
+	// Size of SET values, or integer values for the int phase of
+	// -encoding-compare. With -value-size-max each SET picks a size in
+	// [valueSize, valueSizeMax].
+	valueSize    int
+	valueSizeMax int
+	intValues    bool
+
+	// budget holds the operations a -requests run may still issue; nil
+	// when the run is timed.
+	budget *requestBudget
+
+	// limiter holds the workers to -rate; nil when unbounded. With
+	// -jitter-free-scheduler the workers follow pacer instead.
+	limiter *rate.Limiter
+	pacer   *rateSchedule
+
+	// Length and operations of the -ramp-down after the run.
+	rampDownTime time.Duration
+	rampDownOps  int
+
+	// SETs written with a TTL, for reporting -ttl-fraction.
+	volatileSets int
+
+	// INFO counters before and after the run for -io-stats.
+	ioBefore, ioAfter map[string]int64
+	serverStats       *serverStatsResult // Only set with -server-stats
+	ioErr             error
+
+	// Operations completed in each second of the run.
+	throughput []float64
+
+	// Selection probabilities steered by -adaptive-mix, or nil.
+	mix *adaptiveMix
+
+	// The operation cycle of -deterministic-mix, or nil.
+	schedule *opSchedule
+
+	// The save triggered by -bgsave-at, or nil.
+	bgsave *bgsaveResult
+
+	// Clients of each worker when they do not share the pool, or nil.
+	workerClients []opClients
+
+	// Dial errors, pool timeouts and command errors of the run.
+	connErrors *ConnErrorReport
+}
+
+// runStats groups the latency statistics recorded during a run.
+type runStats struct {
+	set, get, del operationStats
+	waitAOF       operationStats
+	refresh       operationStats
+	pipeline      operationStats
+	lmpop, zmpop  operationStats
+	lpush, blpop  operationStats
+	command       operationStats
+	publish       operationStats
+	deliver       operationStats // End-to-end delivery latency of -mode pubsub messages
+	scan          operationStats
+	scanIteration operationStats // Duration of full -mode scan iterations
+	incr, hset    operationStats
+	hybridBatch   operationStats
+	hybridOp      operationStats
+	obj           objectStats
+	sizeTiers     [len(sizeTierNames)]sizeTierStats // SET and GET latency by value size, under -value-size-max
+}
+
+func newRunStats() *runStats {
+	s := &runStats{
+		set:           operationStats{minTime: math.MaxFloat64},
+		get:           operationStats{minTime: math.MaxFloat64},
+		del:           operationStats{minTime: math.MaxFloat64},
+		waitAOF:       operationStats{minTime: math.MaxFloat64},
+		refresh:       operationStats{minTime: math.MaxFloat64},
+		pipeline:      operationStats{minTime: math.MaxFloat64},
+		lmpop:         operationStats{minTime: math.MaxFloat64},
+		zmpop:         operationStats{minTime: math.MaxFloat64},
+		lpush:         operationStats{minTime: math.MaxFloat64},
+		blpop:         operationStats{minTime: math.MaxFloat64},
+		incr:          operationStats{minTime: math.MaxFloat64},
+		command:       operationStats{minTime: math.MaxFloat64},
+		publish:       operationStats{minTime: math.MaxFloat64},
+		deliver:       operationStats{minTime: math.MaxFloat64},
+		scan:          operationStats{minTime: math.MaxFloat64},
+		scanIteration: operationStats{minTime: math.MaxFloat64},
+		hset:          operationStats{minTime: math.MaxFloat64},
+		hybridBatch:   operationStats{minTime: math.MaxFloat64},
+		hybridOp:      operationStats{minTime: math.MaxFloat64},
+		obj: objectStats{
+			idleLatency: operationStats{minTime: math.MaxFloat64},
+			freqLatency: operationStats{minTime: math.MaxFloat64},
+			idleValues:  operationStats{minTime: math.MaxFloat64},
+			freqValues:  operationStats{minTime: math.MaxFloat64},
+		},
+	}
+	for i := range s.sizeTiers {
+		s.sizeTiers[i].set.minTime = math.MaxFloat64
+		s.sizeTiers[i].get.minTime = math.MaxFloat64
+	}
+	return s
+}
+
+// merge adds the statistics recorded in o to s.
+func (s *runStats) merge(o *runStats) {
+	s.set.merge(&o.set)
+	s.get.merge(&o.get)
+	s.del.merge(&o.del)
+	s.waitAOF.merge(&o.waitAOF)
+	s.refresh.merge(&o.refresh)
+	s.pipeline.merge(&o.pipeline)
+	s.lmpop.merge(&o.lmpop)
+	s.zmpop.merge(&o.zmpop)
+	s.lpush.merge(&o.lpush)
+	s.blpop.merge(&o.blpop)
+	s.incr.merge(&o.incr)
+	s.command.merge(&o.command)
+	s.publish.merge(&o.publish)
+	s.deliver.merge(&o.deliver)
+	s.scan.merge(&o.scan)
+	s.scanIteration.merge(&o.scanIteration)
+	s.hset.merge(&o.hset)
+	s.hybridBatch.merge(&o.hybridBatch)
+	s.hybridOp.merge(&o.hybridOp)
+	s.obj.idleLatency.merge(&o.obj.idleLatency)
+	s.obj.freqLatency.merge(&o.obj.freqLatency)
+	s.obj.idleValues.merge(&o.obj.idleValues)
+	s.obj.freqValues.merge(&o.obj.freqValues)
+	for i := range s.sizeTiers {
+		s.sizeTiers[i].set.merge(&o.sizeTiers[i].set)
+		s.sizeTiers[i].get.merge(&o.sizeTiers[i].get)
+	}
+}
+
+func newBenchmarkRun(ratios opRatios, clients int) *benchmarkRun {
+	r := &benchmarkRun{
+		ratios:       ratios.normalized(),
+		valueSize:    valueSize.get("set"),
+		valueSizeMax: valueSizeMax,
+		stats:        newRunStats(),
+		limiter:      newRateLimiter(),
+		popCounts:    make(map[string]int),
+		emptyPops:    make(map[string]int),
+	}
+	if adaptiveOpMix {
+		r.mix = newAdaptiveMix(r.ratios)
+	}
+	if deterministicMix {
+		r.schedule = newOpSchedule(r.ratios)
+	}
+	if jitterFreeScheduler {
+		r.pacer = &rateSchedule{interval: float64(time.Second) / targetRate}
+	}
+
+	r.counters = make([]workerCounters, clients)
+	r.workerStats = make([]*runStats, clients)
+	for i := range r.workerStats {
+		r.workerStats[i] = newRunStats()
+	}
+	return r
+}
+
+// execute starts the client workers and the progress reporter, lets them run
+// for duration, or with -requests until that many operations completed, and
+// waits for all workers to finish.
+func (r *benchmarkRun) execute(ctx context.Context, clients opClients, keys []string, duration time.Duration) {
+	r.duration = duration
+	if ioStats {
+		r.ioBefore, r.ioErr = captureIOStats(ctx, clients.set)
+	}
+	if connErrors != nil {
+		connErrors.begin()
+	}
+	if metrics != nil {
+		metrics.attach(r.workerStats)
+		metrics.running.Set(1)
+		defer metrics.running.Set(0)
+	}
+	if numRequests > 0 {
+		r.budget = newRequestBudget(numRequests)
+	}
+	started := time.Now()
+	stop := r.start(ctx, clients, keys, true)
+
+	var saved <-chan bgsaveResult
+	saveCtx, cancelSave := context.WithCancel(ctx)
+	defer cancelSave()
+	if bgsave != nil {
+		saved = bgsave.schedule(saveCtx, bgsaveAt)
+	}
+	var sampled <-chan serverStatsResult
+	stopSampling := make(chan struct{})
+	if serverStatsMon != nil {
+		sampled = serverStatsMon.watch(ctx, stopSampling)
+	}
+
+	// The run ends after its duration or -requests, whichever is configured,
+	// or early on SIGINT/SIGTERM
+	var expired <-chan time.Time
+	var requestsDone <-chan struct{}
+	if r.budget != nil {
+		requestsDone = r.budget.done
+	} else {
+		expired = time.After(duration)
+	}
+	select {
+	case <-expired:
+	case <-requestsDone:
+	case sig := <-signals:
+		releaseSignals()
+		r.interrupted = true
+		r.duration = time.Since(started)
+		fmt.Printf("\nReceived %v, stopping the run after %v...\n", sig, r.duration.Round(time.Millisecond))
+	}
+
+	stop()
+	if requestsDone != nil && !r.interrupted {
+		// Up to the end of the last operations in flight
+		r.duration = time.Since(started)
+	}
+	cancelSave()
+	close(stopSampling)
+	if sampled != nil {
+		res := <-sampled
+		r.serverStats = &res
+	}
+	r.checkErrorRate()
+	r.checkSLA()
+	if connErrors != nil {
+		report := connErrors.report(r.duration)
+		r.connErrors = &report
+		if connErrorsOut != nil {
+			if err := connErrorsOut.write(report); err != nil {
+				logger.Error("Failed to write connection error CSV", "error", err)
+			}
+		}
+	}
+	if saved != nil {
+		res := <-saved
+		bgsave.collect(&res)
+		r.bgsave = &res
+	}
+	if ioStats && r.ioErr == nil {
+		r.ioAfter, r.ioErr = captureIOStats(ctx, clients.set)
+	}
+	if rampDownDuration > 0 && !r.interrupted {
+		r.rampDown(ctx, clients, keys)
+	}
+}
+
+// requestBudget is the number of operations a -requests run may still
+// issue. Workers claim each operation from it before issuing it, so the run
+// issues exactly -requests operations; done is closed once all of them are
+// claimed.
+type requestBudget struct {
+	left atomic.Int64
+	done chan struct{}
+}
+
+func newRequestBudget(n int) *requestBudget {
+	b := &requestBudget{done: make(chan struct{})}
+	b.left.Store(int64(n))
+	return b
+}
+
+// claim takes up to n operations from the budget and returns how many it
+// got, 0 once the budget is spent.
+func (b *requestBudget) claim(n int) int {
+	for {
+		left := b.left.Load()
+		if left <= 0 {
+			return 0
+		}
+		got := min(int64(n), left)
+		if b.left.CompareAndSwap(left, left-got) {
+			if got == left {
+				close(b.done)
+			}
+			return int(got)
+		}
+	}
+}
+
+// claim takes up to n operations from the -requests budget of the run, if
+// it has one, and returns how many the caller may issue.
+func (r *benchmarkRun) claim(n int) int {
+	if r.budget == nil || n == 0 {
+		return n
+	}
+	return r.budget.claim(n)
+}
+
+// start launches the client workers, and the progress reporter when
+// withProgress is set. The returned function stops the workers, waits for
+// them to finish and merges their statistics.
+func (r *benchmarkRun) start(ctx context.Context, clients opClients, keys []string, withProgress bool) func() {
+	var wg sync.WaitGroup
+
+	// Signal channel to stop clients
+	stop := make(chan struct{})
+
+	if r.pacer != nil {
+		r.pacer.begin()
+	}
+
+	// Start client workers
+	for i := range r.counters {
+		wc := clients.worker(i)
+		if r.workerClients != nil {
+			wc = r.workerClients[i]
+		}
+		wg.Add(1)
+		if benchMode == modePubSub {
+			go pubsubWorker(ctx, i+1, wc.set, r, stop, &wg)
+		} else if benchMode == modeScan {
+			go scanWorker(ctx, i+1, wc.set, r, stop, &wg)
+		} else if queueConsumers > 0 {
+			go queueWorker(ctx, i+1, wc.set, r, stop, &wg)
+		} else if pipelineCommands != nil {
+			go pipelineWorker(ctx, i+1, wc.set, keys, pipelineCommands, r, stop, &wg)
+		} else if commandTemplate != nil {
+			go commandWorker(ctx, i+1, wc.set, keys, r, stop, &wg)
+		} else {
+			go clientWorker(ctx, i+1, wc, keys, r, stop, &wg)
+		}
+	}
+
+	// Start statistics reporter. With -output json the live display is
+	// suppressed unless it goes to -progress-file.
+	if withProgress && progressFormat == "json-lines" && (outputFormat == "text" || progressFile != "") {
+		interval := map[string][]*operationStats{}
+		for _, ws := range r.workerStats {
+			interval["set"] = append(interval["set"], &ws.set)
+			interval["get"] = append(interval["get"], &ws.get)
+			interval["del"] = append(interval["del"], &ws.del)
+		}
+		go reportProgressJSON(progressOut, interval, stop)
+	} else if withProgress && progressFormat == "ansi" && outputFormat == "text" && !quiet {
+		go reportProgress(r.counters, stop)
+	}
+	if withProgress {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			r.sampleThroughput(stop)
+		}()
+	}
+	if withProgress && csvOut != nil {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			csvOut.record(r, stop)
+		}()
+	}
+	if r.mix != nil {
+		go r.mix.run(r, stop)
+	}
+
+	return func() {
+		// Signal workers to stop
+		close(stop)
+
+		// Wait for all workers to finish
+		wg.Wait()
+
+		for _, ws := range r.workerStats {
+			r.stats.merge(ws)
+		}
+		r.tally()
+	}
+}
+
+// tally sums the worker counters into the run totals.
+func (r *benchmarkRun) tally() {
+	r.totalSet, r.totalGet, r.totalDel = setGetDel(r.counters)
+	r.totalSetData, r.totalGetData = 0, 0
+	r.waitAOFAchieved, r.getHits, r.queueTimeouts, r.volatileSets = 0, 0, 0, 0
+	for i := range r.counters {
+		c := &r.counters[i]
+		r.totalSetData += atomic.LoadInt64(&c.setBytes)
+		r.totalGetData += atomic.LoadInt64(&c.getBytes)
+		r.waitAOFAchieved += loadCount(&c.waitAOFAchieved)
+		r.getHits += loadCount(&c.getHits)
+		r.queueTimeouts += loadCount(&c.queueTimeouts)
+		r.volatileSets += loadCount(&c.volatileSets)
+	}
+}
+
+// completed returns the number of operations counted in the progress
+// display so far.
+func (r *benchmarkRun) completed() int {
+	total := 0
+	for i := range r.counters {
+		total += r.counters[i].operations()
+	}
+	return total
+}
+
+// report collects the results of the run for -report-template.
+func (r *benchmarkRun) report() Report {
+	report := Report{
+		Clients:         len(r.counters),
+		Keys:            numKeys,
+		Duration:        r.duration,
+		DurationSeconds: r.duration.Seconds(),
+		RampDownSeconds: r.rampDownTime.Seconds(),
+		Rate:            r.rateReport(),
+		SetBytes:        r.totalSetData,
+		GetBytes:        r.totalGetData,
+		SetMBPerSec:     mbPerSec(r.totalSetData, r.duration),
+		GetMBPerSec:     mbPerSec(r.totalGetData, r.duration),
+		Throughput:      r.throughputReport(),
+		Workers:         r.workerReports(r.duration),
+		Tags:            tags,
+		ValueSizes:      r.valueSizes(),
+		SetValueSizeMax: r.valueSizeMax,
+		ConnErrors:      r.connErrors,
+		SizeTiers:       r.sizeTierReports(),
+		Operations: []OperationReport{
+			newOperationReport("SET", &r.stats.set, r.duration),
+			newOperationReport("GET", &r.stats.get, r.duration),
+			newOperationReport("DEL", &r.stats.del, r.duration),
+		},
+	}
+	if waitAOF {
+		report.Operations = append(report.Operations, newOperationReport("WAITAOF", &r.stats.waitAOF, r.duration))
+	}
+	if pipelineDepth > 1 || hybridFraction > 0 {
+		report.Operations = append(report.Operations, newOperationReport("PIPELINE", &r.stats.hybridBatch, r.duration))
+	}
+	for _, op := range r.typedOps() {
+		report.Operations = append(report.Operations, newOperationReport(op.name, op.stats, r.duration))
+	}
+	if commandTemplate != nil {
+		report.Operations = append(report.Operations, newOperationReport(commandName(), &r.stats.command, r.duration))
+	}
+	if benchMode == modePubSub {
+		report.Operations = append(report.Operations,
+			newOperationReport("PUBLISH", &r.stats.publish, r.duration),
+			newOperationReport("DELIVERY", &r.stats.deliver, r.duration))
+	}
+	if benchMode == modeScan {
+		report.Operations = append(report.Operations,
+			newOperationReport("SCAN", &r.stats.scan, r.duration),
+			newOperationReport("SCAN_ITERATION", &r.stats.scanIteration, r.duration))
+	}
+	_, report.ErrorRate = r.errorRate()
+	return report
+}
+
+// printSummary prints the final results of the run, either with the
+// -report-template or as the default plain-text summary.
+func (r *benchmarkRun) printSummary(clients opClients, tmpl *template.Template) {
+	if outputFormat == "json" {
+		if err := json.NewEncoder(resultsOut).Encode(r.report()); err != nil {
+			logger.Error("Failed to write JSON report", "error", err)
+		}
+		return
+	}
+	if tmpl != nil {
+		fmt.Println()
+		if err := tmpl.Execute(os.Stdout, r.report()); err != nil {
+			logger.Error("Failed to render report", "error", err)
+		}
+		return
+	}
+
+	fmt.Println("\nBenchmark complete.")
+	fmt.Printf("Total clients: %d\n", len(r.counters))
+	fmt.Printf("Total keys: %d\n", numKeys)
+	if keyDistribution == distributionZipfian {
+		fmt.Printf("Key distribution: zipfian (s=%v)\n", zipfS)
+	}
+	if batchSize > 1 {
+		fmt.Printf("Batch: SET and GET sent as MSET and MGET of %d keys; latency is per call, and MSET keys have no TTL\n", batchSize)
+	}
+	fmt.Printf("Total time: %v\n", r.duration)
+	if r.interrupted {
+		fmt.Println("Run interrupted: results cover the time until the signal")
+	}
+	if numRequests > 0 {
+		completed := 0
+		for i := range r.counters {
+			completed += r.counters[i].requested()
+		}
+		fmt.Printf("Requests: %d issued, %d completed\n", numRequests-int(r.budget.left.Load()), completed)
+	}
+	if len(tags) > 0 {
+		fmt.Printf("Tags: %s\n", tags)
+	}
+	printValueSizes(r.valueSizes(), r.valueSizeMax)
+	if benchMode == modePubSub || benchMode == modeScan {
+		if benchMode == modePubSub {
+			r.printPubSubSummary()
+		} else {
+			r.printScanSummary()
+		}
+		if r.connErrors != nil {
+			printConnErrors(*r.connErrors)
+		}
+		return
+	}
+	if queueConsumers > 0 {
+		r.printQueueSummary()
+		r.printErrors()
+		if r.connErrors != nil {
+			printConnErrors(*r.connErrors)
+		}
+		return
+	}
+	if commandTemplate != nil {
+		r.printCommandSummary()
+		printThroughput(r.throughputReport())
+		printWorkerTable(r.workerReports(r.duration))
+		r.printDBTotals()
+		if r.connErrors != nil {
+			printConnErrors(*r.connErrors)
+		}
+		return
+	}
+	if pipelineCommands != nil {
+		pipelines := r.stats.pipeline.count
+		fmt.Printf("Pipelines executed: %d (%d commands each, from %s)\n", pipelines, len(pipelineCommands), pipelineFile)
+		fmt.Printf("Average pipelines/sec: %.2f\n", float64(pipelines)/r.duration.Seconds())
+		fmt.Printf("Average commands/sec: %.2f\n", float64(pipelines*len(pipelineCommands))/r.duration.Seconds())
+		r.printErrors()
+		printStats("Pipeline", &r.stats.pipeline)
+		printThroughput(r.throughputReport())
+		printWorkerTable(r.workerReports(r.duration))
+		r.printDBTotals()
+		if r.connErrors != nil {
+			printConnErrors(*r.connErrors)
+		}
+		return
+	}
+	fmt.Printf("SET operations: %d\n", r.totalSet)
+	fmt.Printf("GET operations: %d\n", r.totalGet)
+	fmt.Printf("DEL operations: %d\n", r.totalDel)
+	for _, op := range r.typedOps() {
+		fmt.Printf("%s operations: %d\n", op.name, op.stats.count)
+	}
+	r.printErrors()
+	if r.mix != nil {
+		r.printAdaptiveMix()
+	}
+	if r.ratios.set > 0 {
+		switch {
+		case ttl == 0:
+			fmt.Println("Key TTL: none (SETs write persistent keys)")
+		case ttlJitter > 0:
+			fmt.Printf("Key TTL: %v ± %v\n", ttl, ttlJitter)
+		}
+	}
+	if ttlFraction < 1 && ttl > 0 {
+		applied := 0.0
+		if r.totalSet > 0 {
+			applied = float64(r.volatileSets) / float64(r.totalSet) * 100
+		}
+		fmt.Printf("SETs with TTL %v: %d (%.2f%% applied, target %.2f%%)\n",
+			ttl, r.volatileSets, applied, ttlFraction*100)
+	}
+	if deleteStrategy == "expire" {
+		fmt.Println("Deletion strategy: expire (DEL operations issue EXPIRE 1s and leave removal to lazy/active expiry)")
+	} else {
+		fmt.Println("Deletion strategy: del (explicit DEL)")
+	}
+	if valuePrefix != "" {
+		fmt.Printf("Value prefix: %q (worker metadata: %t)\n", valuePrefix, valuePrefixMeta)
+	}
+	fmt.Printf("Total data sent during SET operations: %.2f MB\n", float64(r.totalSetData)/(1024*1024))
+	fmt.Printf("Total data retrieved during GET operations: %.2f MB\n", float64(r.totalGetData)/(1024*1024))
+	fmt.Printf("Average SET throughput: %.2f MB/s\n", mbPerSec(r.totalSetData, r.duration))
+	fmt.Printf("Average GET throughput: %.2f MB/s\n", mbPerSec(r.totalGetData, r.duration))
+	fmt.Printf("Average SET ops/sec: %.2f\n", float64(r.totalSet)/r.duration.Seconds())
+	fmt.Printf("Average GET ops/sec: %.2f\n", float64(r.totalGet)/r.duration.Seconds())
+	fmt.Printf("Average DEL ops/sec: %.2f\n", float64(r.totalDel)/r.duration.Seconds())
+	if report := r.rateReport(); report != nil {
+		printRate(report)
+	}
+	if r.rampDownTime > 0 {
+		fmt.Printf("Ramp-down: %v from %.2f to 0 ops/sec, %d operations (not in the statistics)\n",
+			r.rampDownTime.Round(time.Millisecond), targetRate, r.rampDownOps)
+	}
+	printThroughput(r.throughputReport())
+	printWorkerTable(r.workerReports(r.duration))
+	r.printDBTotals()
+
+	// Print latency statistics
+	if pipelineDepth > 1 {
+		fmt.Printf("Pipelining: %d operations per pipeline; latency is per pipeline flush (batch latency), not per command\n", pipelineDepth)
+		fmt.Printf("Pipelines executed: %d (%d operations)\n", r.stats.hybridBatch.count, r.stats.hybridOp.count)
+		printStats("Pipeline", &r.stats.hybridBatch)
+		printStats("Pipelined op (amortized)", &r.stats.hybridOp)
+	} else {
+		for _, op := range []typedOp{
+			{"SET", r.ratios.set, &r.stats.set},
+			{"GET", r.ratios.get, &r.stats.get},
+			{"DEL", r.ratios.del, &r.stats.del},
+		} {
+			if op.ratio > 0 {
+				printStats(op.name, op.stats)
+			}
+		}
+		for _, op := range r.typedOps() {
+			printStats(op.name, op.stats)
+		}
+		printSizeTiers(r.sizeTierReports())
+	}
+	if ttfb != nil {
+		printTTFB(ttfb, &r.stats.get)
+	}
+	if floors != nil {
+		printLatencyFloor(floors, map[string]*operationStats{
+			"SET": &r.stats.set, "GET": &r.stats.get, "DEL": &r.stats.del,
+		})
+	}
+	if hybridFraction > 0 {
+		fmt.Printf("Hybrid mode: %.0f%% of operations pipelined, %d per pipeline (SET/GET/DEL latency above is standalone only)\n",
+			hybridFraction*100, hybridDepth)
+		fmt.Printf("Pipelines executed: %d (%d operations)\n", r.stats.hybridBatch.count, r.stats.hybridOp.count)
+		printStats("Pipeline", &r.stats.hybridBatch)
+		printStats("Pipelined op (amortized)", &r.stats.hybridOp)
+	}
+	if waitAOF {
+		// Failed calls and short acknowledgements both count as not achieved
+		issued := r.stats.waitAOF.count + r.stats.waitAOF.errors
+		achievedPct := 0.0
+		if issued > 0 {
+			achievedPct = float64(r.waitAOFAchieved) / float64(issued) * 100
+		}
+		fmt.Printf("WAITAOF numlocal=%d numreplicas=%d: durability achieved %d/%d (%.2f%%), %d failed\n",
+			waitAOFLocal, waitAOFReplicas, r.waitAOFAchieved, issued, achievedPct, r.stats.waitAOF.errors)
+		printStats("WAITAOF", &r.stats.waitAOF)
+	}
+	switch refreshTTLOnGet {
+	case "getex":
+		fmt.Println("GET TTL refresh: GETEX (GET latency above includes the refresh)")
+	case "expire":
+		printStats("EXPIRE refresh", &r.stats.refresh)
+		getAvg, refreshAvg := 0.0, 0.0
+		if r.stats.get.count > 0 {
+			getAvg = r.stats.get.totalTime / float64(r.stats.get.count)
+		}
+		if r.stats.refresh.count > 0 {
+			refreshAvg = r.stats.refresh.totalTime / float64(r.stats.refresh.count)
+		}
+		overhead := 0.0
+		if getAvg > 0 {
+			overhead = refreshAvg / getAvg * 100
+		}
+		fmt.Printf("GET TTL refresh overhead: +%.2f ms per GET hit (%.1f%% of plain GET)\n", refreshAvg, overhead)
+	}
+	if r.ratios.idletime > 0 {
+		printStats("OBJECT IDLETIME", &r.stats.obj.idleLatency)
+		printValueStats("OBJECT IDLETIME", "s", &r.stats.obj.idleValues)
+	}
+	if r.ratios.freq > 0 {
+		printStats("OBJECT FREQ", &r.stats.obj.freqLatency)
+		printValueStats("OBJECT FREQ", "counter", &r.stats.obj.freqValues)
+	}
+	if r.ratios.lmpop > 0 {
+		printStats("LMPOP", &r.stats.lmpop)
+		printPopCounts("LMPOP", mpopKeys("list"), r.popCounts, r.emptyPops["LMPOP"])
+	}
+	if r.ratios.zmpop > 0 {
+		printStats("ZMPOP", &r.stats.zmpop)
+		printPopCounts("ZMPOP", mpopKeys("zset"), r.popCounts, r.emptyPops["ZMPOP"])
+	}
+	if injectLatency > 0 {
+		var total float64
+		var count int
+		for _, s := range []*operationStats{&r.stats.set, &r.stats.get, &r.stats.del} {
+			total += s.totalTime
+			count += s.count
+		}
+		measured := 0.0
+		if count > 0 {
+			measured = total / float64(count)
+		}
+		fmt.Printf("Injected latency: configured=%.2f ms per round trip, measured average=%.2f ms\n",
+			float64(injectLatency.Microseconds())/1000, measured)
+	}
+	if tracker != nil {
+		achieved := 0.0
+		if r.totalGet > 0 {
+			achieved = float64(r.getHits) / float64(r.totalGet) * 100
+		}
+		fmt.Printf("Keyspace hit ratio: target=%.2f%%, achieved=%.2f%% (%d hits of %d GETs)\n",
+			keyspaceHitRatio*100, achieved, r.getHits, r.totalGet)
+	}
+	if ioStats {
+		r.printIOStats()
+	}
+	if r.serverStats != nil {
+		printServerStats(r.serverStats)
+	}
+	if r.bgsave != nil {
+		printBGSave(r.bgsave)
+	}
+	if churn != nil {
+		churn.print(r.completed())
+	}
+	if checksums != nil {
+		checked, mismatches := checksums.counts()
+		fmt.Printf("Checksum verification: checked=%d, integrity errors=%d\n", checked, mismatches)
+	}
+	if faults != nil {
+		failures, delays := faults.counts()
+		fmt.Printf("Injected faults (rate %.2f%%, mode %s): failures=%d, delays=%d of %v\n",
+			faultRate*100, faultMode, failures, delays, faultDelay)
+		fmt.Println("Failed operations are not counted in the totals or latency statistics above.")
+	}
+	if oomPause > 0 {
+		fmt.Printf("OOM write pauses: %d (cooldown %v)\n", r.oom.pauses, oomPause)
+	}
+
+	if r.connErrors != nil {
+		printConnErrors(*r.connErrors)
+	}
+	if isolateOps {
+		printPoolStats("SET", clients.set)
+		printPoolStats("GET", clients.get)
+		printPoolStats("DEL", clients.del)
+	}
+	printMemStats()
+}
+
+// printPhaseComparison prints throughput and average latency of two runs
+// side by side, with the relative change from phase A to phase B.
+func printPhaseComparison(a, b *benchmarkRun) {
+	printReportComparison("Phase comparison (B vs A):", a.report(), b.report())
+}
+
+// printReportComparison prints the operations of two reports side by side,
+// with the relative change from a to b: throughput and average latency,
+// then the percentiles of the merged histograms. Operations missing from b
+// or run by neither are skipped.
+func printReportComparison(title string, ra, rb Report) {
+	fmt.Println("\n" + title)
+	fmt.Printf("%-16s %14s %14s %9s %12s %12s %9s\n",
+		"Operation", "A ops/sec", "B ops/sec", "Delta", "A avg (ms)", "B avg (ms)", "Delta")
+
+	opsB := map[string]OperationReport{}
+	for _, op := range rb.Operations {
+		opsB[op.Name] = op
+	}
+	for _, opA := range ra.Operations {
+		opB, ok := opsB[opA.Name]
+		if !ok || opA.Count+opB.Count == 0 {
+			continue
+		}
+		latencyDelta := "n/a"
+		if opA.Count > 0 && opB.Count > 0 {
+			latencyDelta = percentChange(opA.AvgMs, opB.AvgMs)
+		}
+		fmt.Printf("%-16s %14.2f %14.2f %9s %12.3f %12.3f %9s\n",
+			opA.Name, opA.OpsPerSec, opB.OpsPerSec, percentChange(opA.OpsPerSec, opB.OpsPerSec),
+			opA.AvgMs, opB.AvgMs, latencyDelta)
+	}
+
+	fmt.Printf("\n%-22s %12s %12s %9s\n", "Percentile", "A (ms)", "B (ms)", "Delta")
+	for _, opA := range ra.Operations {
+		opB, ok := opsB[opA.Name]
+		if !ok || opA.Count == 0 || opB.Count == 0 {
+			continue
+		}
+		for _, p := range []struct {
+			name string
+			a, b float64
+		}{
+			{"P50", opA.P50Ms, opB.P50Ms},
+			{"P99", opA.P99Ms, opB.P99Ms},
+			{"P99.9", opA.P999Ms, opB.P999Ms},
+		} {
+			// Zero when a merged report lacked the histograms
+			if p.a == 0 || p.b == 0 {
+				continue
+			}
+			fmt.Printf("%-22s %12.3f %12.3f %9s\n", opA.Name+" "+p.name, p.a, p.b, percentChange(p.a, p.b))
+		}
+	}
+}
+
+// mbPerSec converts a byte count transferred over d into MB/s.
+func mbPerSec(bytes int64, d time.Duration) float64 {
+	return float64(bytes) / (1024 * 1024) / d.Seconds()
+}
+
+// percentChange formats the relative change from a to b.
+func percentChange(a, b float64) string {
+	if a == 0 {
+		return "n/a"
+	}
+	return fmt.Sprintf("%+.1f%%", (b-a)/a*100)
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+const modeScan = "scan"
+
+// scanWorker runs -mode scan: it follows the SCAN cursor from 0 until the
+// server returns 0 again, records how long the full iteration took, and
+// starts over. An iteration cut short by the end of the run is not counted.
+func scanWorker(
+	ctx context.Context,
+	workerID int,
+	client benchClient,
+	run *benchmarkRun,
+	stop <-chan struct{},
+	wg *sync.WaitGroup,
+) {
+	defer wg.Done()
+
+	stats := run.workerStats[workerID-1]
+	counters := &run.counters[workerID-1]
+	runCtx, cancel := stopContext(ctx, stop)
+	defer cancel()
+
+	var cursor uint64
+	var keys, calls int64
+	iterationStart := time.Now()
+	for {
+		select {
+		case <-stop:
+			return
+		default:
+		}
+		if run.pace(runCtx, 1) == 0 {
+			return
+		}
+		start := time.Now()
+		var cmd *redis.ScanCmd
+		if scanType != "" {
+			cmd = client.ScanType(ctx, cursor, scanMatch, scanCount, scanType)
+		} else {
+			cmd = client.Scan(ctx, cursor, scanMatch, scanCount)
+		}
+		page, next, err := cmd.Result()
+		if err != nil {
+			recordError(&stats.scan)
+			continue
+		}
+		updateStats(&stats.scan, time.Since(start).Seconds()*1000)
+		atomic.AddInt64(&counters.scan, 1)
+		keys += int64(len(page))
+		calls++
+
+		cursor = next
+		if cursor == 0 {
+			updateStats(&stats.scanIteration, time.Since(iterationStart).Seconds()*1000)
+			atomic.AddInt64(&counters.scanIterations, 1)
+			atomic.AddInt64(&counters.scanKeys, keys)
+			atomic.AddInt64(&counters.scanKeysCalls, calls)
+			keys, calls = 0, 0
+			iterationStart = time.Now()
+		}
+	}
+}
+
+// printScanSummary prints the results of a -mode scan run. SCAN may return
+// a key more than once, so keys seen can exceed the size of the keyspace.
+func (r *benchmarkRun) printScanSummary() {
+	iterations, keys, calls := 0, 0, 0
+	for i := range r.counters {
+		iterations += loadCount(&r.counters[i].scanIterations)
+		keys += loadCount(&r.counters[i].scanKeys)
+		calls += loadCount(&r.counters[i].scanKeysCalls)
+	}
+	match := scanMatch
+	if match == "" {
+		match = "(all keys)"
+	}
+	count := "server default"
+	if scanCount > 0 {
+		count = fmt.Sprint(scanCount)
+	}
+	keyType := scanType
+	if keyType == "" {
+		keyType = "(all types)"
+	}
+	fmt.Printf("Scan: MATCH %s, TYPE %s, COUNT %s\n", match, keyType, count)
+	fmt.Printf("Iterations completed: %d (%.2f iterations/sec)\n", iterations, float64(iterations)/r.duration.Seconds())
+	keysPerIteration, keysPerCall := 0.0, 0.0
+	if iterations > 0 {
+		keysPerIteration = float64(keys) / float64(iterations)
+	}
+	if calls > 0 {
+		keysPerCall = float64(keys) / float64(calls)
+	}
+	fmt.Printf("Keys seen: %d (%.1f per iteration, %.2f per SCAN call)\n", keys, keysPerIteration, keysPerCall)
+	r.printErrors()
+	printStats("SCAN", &r.stats.scan)
+	printStats("Iteration", &r.stats.scanIteration)
+	printThroughput(r.throughputReport())
+	printWorkerTable(r.workerReports(r.duration))
+}
+
+// runScanBaseline repeats a -mode scan run without MATCH and TYPE and
+// compares the two, which shows the cost of the server-side filtering:
+// the filtered run reads the same pages but returns fewer keys.
+func runScanBaseline(ctx context.Context, ratios opRatios, clients opClients, keys []string, filtered *benchmarkRun) {
+	match, keyType := scanMatch, scanType
+	scanMatch, scanType = "", ""
+	defer func() { scanMatch, scanType = match, keyType }()
+
+	fmt.Println("\nUnfiltered SCAN baseline")
+	run := newBenchmarkRun(ratios, len(filtered.counters))
+	run.execute(ctx, clients, keys, testDuration)
+	printReportComparison("SCAN filter cost (B = filtered vs A = unfiltered):", run.report(), filtered.report())
+}
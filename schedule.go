@@ -0,0 +1,76 @@
+package main
+
+import (
+	"math"
+	"sort"
+	"sync/atomic"
+)
+
+// schedulePeriod is the length of the -deterministic-mix schedule. Ratios
+// are rounded to 1/schedulePeriod, and every schedulePeriod consecutive
+// selections contain each operation exactly its rounded share of times.
+const schedulePeriod = 1000
+
+// opSchedule replaces the random operation choice of -deterministic-mix with
+// a fixed cycle shared by all workers. The cycle interleaves the operations
+// by smooth weighted round-robin, so any prefix of it is within one
+// selection of the ratios, not just the whole cycle.
+type opSchedule struct {
+	// values holds for each slot of the cycle a selection value in the
+	// middle of the chosen operation's range, as workers compare it with
+	// the cumulative ratios just like a random one.
+	values []float64
+	next   atomic.Int64
+}
+
+func newOpSchedule(r opRatios) *opSchedule {
+	// Same order as the selection in clientWorker
+	weights := []float64{r.set, r.get, r.del, r.idletime, r.freq, r.lmpop, r.zmpop, r.incr, r.hset, r.lpush}
+
+	// Round to whole slots, giving the slots lost to rounding down to the
+	// largest remainders
+	counts := make([]int, len(weights))
+	order := make([]int, len(weights))
+	left := schedulePeriod
+	for i, w := range weights {
+		counts[i] = int(math.Floor(w * schedulePeriod))
+		left -= counts[i]
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		ra := weights[order[a]]*schedulePeriod - float64(counts[order[a]])
+		rb := weights[order[b]]*schedulePeriod - float64(counts[order[b]])
+		return ra > rb
+	})
+	for _, i := range order[:left] {
+		counts[i]++
+	}
+
+	mid := make([]float64, len(weights))
+	start := 0.0
+	for i, w := range weights {
+		mid[i] = start + w/2
+		start += w
+	}
+
+	s := &opSchedule{values: make([]float64, schedulePeriod)}
+	current := make([]int, len(weights))
+	for slot := range s.values {
+		best := -1
+		for i, c := range counts {
+			current[i] += c
+			if c > 0 && (best < 0 || current[i] > current[best]) {
+				best = i
+			}
+		}
+		current[best] -= schedulePeriod
+		s.values[slot] = mid[best]
+	}
+	return s
+}
+
+// pick returns the selection value of the next slot of the cycle.
+func (s *opSchedule) pick() float64 {
+	n := s.next.Add(1) - 1
+	return s.values[n%schedulePeriod]
+}
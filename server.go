@@ -0,0 +1,50 @@
+package main
+
+import (
+	"context"
+	"strconv"
+	"strings"
+)
+
+// parseInfo turns the text returned by INFO into a map of field to value.
+// Section headers and blank lines are skipped.
+func parseInfo(info string) map[string]string {
+	fields := make(map[string]string)
+	for _, line := range strings.Split(info, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if k, v, ok := strings.Cut(line, ":"); ok {
+			fields[k] = v
+		}
+	}
+	return fields
+}
+
+// serverVersion returns the redis_version reported by INFO server.
+func serverVersion(ctx context.Context, rdb benchClient) (string, error) {
+	info, err := rdb.Info(ctx, "server").Result()
+	if err != nil {
+		return "", err
+	}
+	return parseInfo(info)["redis_version"], nil
+}
+
+// versionAtLeast reports whether a dotted version string such as "7.2.4" is
+// greater than or equal to major.minor.
+func versionAtLeast(version string, major, minor int) bool {
+	parts := strings.SplitN(version, ".", 3)
+	if len(parts) < 2 {
+		return false
+	}
+	maj, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return false
+	}
+	min, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return false
+	}
+	return maj > major || (maj == major && min >= minor)
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// serverStatsInterval is how often -server-stats samples INFO during a run.
+const serverStatsInterval = time.Second
+
+// serverStatFields are the INFO fields -server-stats reports the change of
+// over a run.
+var serverStatFields = []string{"used_memory", "evicted_keys", "keyspace_hits", "keyspace_misses"}
+
+// serverStatsMonitor samples the server's memory, eviction and keyspace
+// counters during each run, on its own connection so the INFO calls are
+// not counted as benchmark latency.
+type serverStatsMonitor struct {
+	admin *redis.Client
+}
+
+// serverStatsResult is what -server-stats observed over one run.
+type serverStatsResult struct {
+	before, after map[string]int64
+	peakMemory    int64
+	samples       int
+	err           error
+}
+
+func newServerStatsMonitor(admin *redis.Client) *serverStatsMonitor {
+	return &serverStatsMonitor{admin: admin}
+}
+
+// captureServerStats reads serverStatFields from INFO memory, stats and
+// keyspace. Servers before Redis 7 accept only one section, so it falls
+// back to INFO all.
+func captureServerStats(ctx context.Context, rdb *redis.Client) (map[string]int64, error) {
+	info, err := rdb.Info(ctx, "memory", "stats", "keyspace").Result()
+	if err != nil {
+		if info, err = rdb.Info(ctx, "all").Result(); err != nil {
+			return nil, err
+		}
+	}
+	fields := parseInfo(info)
+	stats := make(map[string]int64)
+	for _, name := range serverStatFields {
+		if v, err := strconv.ParseInt(fields[name], 10, 64); err == nil {
+			stats[name] = v
+		}
+	}
+	return stats, nil
+}
+
+// watch samples INFO now, every serverStatsInterval and once more when stop
+// is closed, then sends the result.
+func (m *serverStatsMonitor) watch(ctx context.Context, stop <-chan struct{}) <-chan serverStatsResult {
+	done := make(chan serverStatsResult, 1)
+	go func() {
+		var res serverStatsResult
+		sample := func() {
+			stats, err := captureServerStats(ctx, m.admin)
+			if err != nil {
+				if res.err == nil {
+					res.err = err
+				}
+				return
+			}
+			if res.before == nil {
+				res.before = stats
+			}
+			res.after = stats
+			res.peakMemory = max(res.peakMemory, stats["used_memory"])
+			res.samples++
+		}
+
+		sample()
+		ticker := time.NewTicker(serverStatsInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				sample()
+			case <-stop:
+				sample()
+				done <- res
+				return
+			}
+		}
+	}()
+	return done
+}
+
+// printServerStats prints the change of the server counters over the run
+// and the highest memory use sampled.
+func printServerStats(res *serverStatsResult) {
+	if res.before == nil || res.samples < 2 {
+		fmt.Printf("Server stats unavailable: %v\n", res.err)
+		return
+	}
+	delta := func(name string) (int64, bool) {
+		before, ok1 := res.before[name]
+		after, ok2 := res.after[name]
+		return after - before, ok1 && ok2
+	}
+
+	fmt.Printf("Server stats (INFO deltas, %d samples):\n", res.samples)
+	if d, ok := delta("used_memory"); ok {
+		fmt.Printf("  Used memory: %+.2f MB (%.2f MB at the end, peak %.2f MB)\n",
+			float64(d)/(1024*1024), float64(res.after["used_memory"])/(1024*1024), float64(res.peakMemory)/(1024*1024))
+	}
+	if d, ok := delta("evicted_keys"); ok {
+		fmt.Printf("  Evicted keys: %d\n", d)
+	}
+	hits, okHits := delta("keyspace_hits")
+	misses, okMisses := delta("keyspace_misses")
+	if okHits && okMisses {
+		ratio := 0.0
+		if hits+misses > 0 {
+			ratio = float64(hits) / float64(hits+misses) * 100
+		}
+		fmt.Printf("  Keyspace hits: %d, misses: %d (%.2f%% hit ratio)\n", hits, misses, ratio)
+	}
+}
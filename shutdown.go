@@ -0,0 +1,41 @@
+package main
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// signals receives SIGINT and SIGTERM once the measured runs begin, so an
+// interrupted run stops its workers and still prints what it collected.
+// It is nil before then, and receiving from it blocks forever.
+var signals chan os.Signal
+
+// catchSignals installs the handler that ends the current run early.
+func catchSignals() {
+	signals = make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+}
+
+// exitHooks run, newest first, when the process exits through exit, for
+// cleanup that must also happen when a run is aborted from a goroutine.
+var exitHooks []func()
+
+// atExit registers f to run on exit.
+func atExit(f func()) {
+	exitHooks = append(exitHooks, f)
+}
+
+// exit runs the exit hooks and ends the process with code.
+func exit(code int) {
+	for i := len(exitHooks) - 1; i >= 0; i-- {
+		exitHooks[i]()
+	}
+	os.Exit(code)
+}
+
+// releaseSignals restores the default signal behaviour, so a second Ctrl-C
+// kills the process if shutting down the interrupted run hangs.
+func releaseSignals() {
+	signal.Stop(signals)
+}
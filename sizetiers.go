@@ -0,0 +1,103 @@
+package main
+
+import "fmt"
+
+// sizeTierNames are the value size tiers of a -value-size-max run, which
+// split the range of SET value sizes into thirds.
+var sizeTierNames = [...]string{"small", "medium", "large"}
+
+// sizeTierStats holds the latencies of the SETs and GET hits whose values
+// fall in one size tier.
+type sizeTierStats struct {
+	set, get operationStats
+}
+
+// SizeTierReport is the SET and GET latency of the values of one size tier,
+// from MinBytes to MaxBytes.
+type SizeTierReport struct {
+	Tier       string            `json:"tier"`
+	MinBytes   int               `json:"min_bytes"`
+	MaxBytes   int               `json:"max_bytes"`
+	Operations []OperationReport `json:"operations"`
+}
+
+// sizeTier returns the tier of a value of n bytes when SET values range
+// from minSize to maxSize. Longer values, such as those with a
+// -value-prefix, count in the largest tier.
+func sizeTier(n, minSize, maxSize int) int {
+	t := (n - minSize) * len(sizeTierNames) / (maxSize - minSize + 1)
+	return min(max(t, 0), len(sizeTierNames)-1)
+}
+
+// sizeTierBounds returns the smallest and largest value size of tier t.
+func sizeTierBounds(t, minSize, maxSize int) (lo, hi int) {
+	width, n := maxSize-minSize+1, len(sizeTierNames)
+	lo = minSize + (t*width+n-1)/n
+	hi = minSize + ((t+1)*width+n-1)/n - 1
+	return lo, hi
+}
+
+// hasSizeTiers reports whether the run writes values of varying size one
+// command at a time, and so records its latencies per size tier. Pipelines
+// and -batch batches mix sizes in one latency sample.
+func (r *benchmarkRun) hasSizeTiers() bool {
+	return r.valueSizeMax > r.valueSize && pipelineDepth <= 1 && batchSize <= 1
+}
+
+// recordSizeTier adds the latency of a SET or GET of a value of n bytes to
+// its size tier.
+func (r *benchmarkRun) recordSizeTier(stats *runStats, get bool, n int, ms float64) {
+	if !r.hasSizeTiers() {
+		return
+	}
+	tier := &stats.sizeTiers[sizeTier(n, r.valueSize, r.valueSizeMax)]
+	if get {
+		updateStats(&tier.get, ms)
+	} else {
+		updateStats(&tier.set, ms)
+	}
+}
+
+// sizeTierReports returns the latency of each size tier, or nil when the
+// value size does not vary.
+func (r *benchmarkRun) sizeTierReports() []SizeTierReport {
+	if !r.hasSizeTiers() {
+		return nil
+	}
+	reports := make([]SizeTierReport, len(sizeTierNames))
+	for i, name := range sizeTierNames {
+		lo, hi := sizeTierBounds(i, r.valueSize, r.valueSizeMax)
+		tier := &r.stats.sizeTiers[i]
+		reports[i] = SizeTierReport{
+			Tier:     name,
+			MinBytes: lo,
+			MaxBytes: hi,
+			Operations: []OperationReport{
+				newOperationReport("SET", &tier.set, r.duration),
+				newOperationReport("GET", &tier.get, r.duration),
+			},
+		}
+		// Only merge needs the encoded histograms, and it drops the tiers
+		for j := range reports[i].Operations {
+			reports[i].Operations[j].Histogram = ""
+		}
+	}
+	return reports
+}
+
+// printSizeTiers prints the SET and GET latency of each value size tier.
+// Pipelined and batched operations are not included.
+func printSizeTiers(tiers []SizeTierReport) {
+	if tiers == nil {
+		return
+	}
+	fmt.Println("Latency by value size (ms):")
+	fmt.Printf("%-7s %15s %10s %8s %8s %10s %8s %8s\n",
+		"Tier", "Bytes", "SETs", "P50", "P99", "GET hits", "P50", "P99")
+	for _, t := range tiers {
+		set, get := t.Operations[0], t.Operations[1]
+		fmt.Printf("%-7s %15s %10d %8.2f %8.2f %10d %8.2f %8.2f\n",
+			t.Tier, fmt.Sprintf("%d-%d", t.MinBytes, t.MaxBytes),
+			set.Count, set.P50Ms, set.P99Ms, get.Count, get.P50Ms, get.P99Ms)
+	}
+}
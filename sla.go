@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// exitSLAViolation is the exit status when the p99 latency of an operation
+// over a whole run exceeds its -sla-p99 limit.
+const exitSLAViolation = 6
+
+// slaViolations lists the -sla-p99 limits that the runs of the invocation
+// exceeded, one line each.
+var slaViolations []string
+
+// slaLimits are the p99 latency limits of -sla-p99 in milliseconds: all
+// applies to every operation without a limit of its own in ops, keyed by
+// operation name in upper case. Zero means no limit.
+type slaLimits struct {
+	all float64
+	ops map[string]float64
+}
+
+// parseSLA parses -sla-p99: a limit for every operation such as "5", limits
+// per operation such as "SET=2,GET=1.5", or both, as in "5,GET=1".
+func parseSLA(spec string) (slaLimits, error) {
+	limits := slaLimits{ops: map[string]float64{}}
+	for _, part := range strings.Split(spec, ",") {
+		name, value, perOp := strings.Cut(strings.TrimSpace(part), "=")
+		if !perOp {
+			name, value = "", name
+		}
+		ms, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err != nil || ms <= 0 {
+			return slaLimits{}, fmt.Errorf("invalid limit %q: expected a positive number of milliseconds", part)
+		}
+		if !perOp {
+			limits.all = ms
+			continue
+		}
+		name = strings.ToUpper(strings.TrimSpace(name))
+		if name == "" {
+			return slaLimits{}, fmt.Errorf("invalid limit %q: missing operation name", part)
+		}
+		limits.ops[name] = ms
+	}
+	return limits, nil
+}
+
+// checkSLA compares the p99 latency of every operation the run measured
+// with its -sla-p99 limit and records the violations. A limit for an
+// operation that never ran is reported too, so a misspelled name does not
+// silently pass.
+func (r *benchmarkRun) checkSLA() {
+	if slaSpec == "" {
+		return
+	}
+	measured := map[string]bool{}
+	for _, op := range r.report().Operations {
+		if op.Count == 0 {
+			continue
+		}
+		measured[op.Name] = true
+		limit, ok := sla.ops[op.Name]
+		if !ok {
+			limit = sla.all
+		}
+		if limit > 0 && op.P99Ms > limit {
+			slaViolations = append(slaViolations,
+				fmt.Sprintf("%s p99 %.2f ms exceeds -sla-p99 %g ms", op.Name, op.P99Ms, limit))
+		}
+	}
+	for name := range sla.ops {
+		if !measured[name] {
+			slaViolations = append(slaViolations,
+				fmt.Sprintf("%s has an -sla-p99 limit but was not measured", name))
+		}
+	}
+}
+
+// exitOnSLA prints the -sla-p99 violations and exits with exitSLAViolation
+// if there were any. Like exitOnErrorRate main calls it once run has
+// returned, so the run's deferred cleanup has already happened.
+func exitOnSLA() {
+	if len(slaViolations) == 0 {
+		return
+	}
+	for _, v := range slaViolations {
+		fmt.Fprintf(os.Stderr, "SLA violation: %s\n", v)
+	}
+	exit(exitSLAViolation)
+}
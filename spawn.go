@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// spawnReadyTimeout is how long a spawned redis-server may take to accept
+// connections.
+const spawnReadyTimeout = 10 * time.Second
+
+// spawnedServer is a redis-server subprocess started with -spawn-server.
+type spawnedServer struct {
+	cmd      *exec.Cmd
+	exited   chan struct{} // Closed once the process has exited
+	waitErr  error         // Set before exited is closed
+	stopOnce sync.Once
+}
+
+// spawnServer starts binary with the optional config file, listening on the
+// port of addr, and waits until it answers PING. A port already in use, or
+// a server that exits before answering, is an error.
+func spawnServer(binary, config, addr string) (*spawnedServer, error) {
+	_, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, fmt.Errorf("-addr %q: %w", addr, err)
+	}
+
+	// Otherwise the PINGs below would be answered by the server already
+	// holding the port while the spawned one fails to bind
+	if c, err := net.DialTimeout("tcp", addr, time.Second); err == nil {
+		c.Close()
+		return nil, fmt.Errorf("%s is already accepting connections", addr)
+	}
+
+	var args []string
+	if config != "" {
+		args = append(args, config)
+	}
+	// Command-line options override the config file
+	args = append(args, "--port", port)
+
+	cmd := exec.Command(binary, args...)
+	cmd.Stdout = os.Stderr
+	cmd.Stderr = os.Stderr
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	s := &spawnedServer{cmd: cmd, exited: make(chan struct{})}
+	go func() {
+		s.waitErr = cmd.Wait()
+		close(s.exited)
+	}()
+
+	rdb := redis.NewClient(&redis.Options{Addr: addr, MaxRetries: -1})
+	defer rdb.Close()
+	deadline := time.Now().Add(spawnReadyTimeout)
+	for {
+		err := rdb.Ping(context.Background()).Err()
+		// Checked after the PING, so a server that answered and then exited
+		// is not taken for the spawned one
+		select {
+		case <-s.exited:
+			return nil, fmt.Errorf("exited before accepting connections: %v", s.waitErr)
+		default:
+		}
+		if err == nil {
+			return s, nil
+		}
+		if time.Now().After(deadline) {
+			s.stop()
+			return nil, fmt.Errorf("not ready after %v: %w", spawnReadyTimeout, err)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+func (s *spawnedServer) pid() int {
+	return s.cmd.Process.Pid
+}
+
+// stop asks the server to shut down and kills it if it has not exited
+// within a few seconds. Only the first call has an effect.
+func (s *spawnedServer) stop() {
+	s.stopOnce.Do(func() {
+		s.cmd.Process.Signal(os.Interrupt)
+		select {
+		case <-s.exited:
+		case <-time.After(5 * time.Second):
+			s.cmd.Process.Kill()
+			<-s.exited
+		}
+	})
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+var tagKeyPattern = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// reservedTags are the label names the benchmark uses itself, plus those
+// Prometheus reserves, so user tags can never shadow them.
+var reservedTags = map[string]bool{
+	"op": true, "operation": true, "worker": true, "quantile": true, "le": true,
+	"job": true, "instance": true,
+}
+
+// tagFlags collects repeated -tag key=value flags.
+type tagFlags map[string]string
+
+func (t tagFlags) String() string {
+	keys := make([]string, 0, len(t))
+	for k := range t {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for i, k := range keys {
+		keys[i] = k + "=" + t[k]
+	}
+	return strings.Join(keys, ",")
+}
+
+func (t tagFlags) Set(s string) error {
+	key, value, ok := strings.Cut(s, "=")
+	if !ok || value == "" {
+		return fmt.Errorf("expected key=value, got %q", s)
+	}
+	if !tagKeyPattern.MatchString(key) || strings.HasPrefix(key, "__") {
+		return fmt.Errorf("invalid tag key %q: use letters, digits and underscores, not starting with a digit or __", key)
+	}
+	if reservedTags[key] {
+		return fmt.Errorf("tag key %q is reserved", key)
+	}
+	if _, dup := t[key]; dup {
+		return fmt.Errorf("tag %q given more than once", key)
+	}
+	t[key] = value
+	return nil
+}
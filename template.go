@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// commandName is the name -command is reported under: its first word in
+// upper case, such as SETEX.
+func commandName() string {
+	return strings.ToUpper(commandTemplate[0])
+}
+
+// commandWorker repeatedly sends the -command template with its
+// placeholders filled in, drawing a new key, value and random number for
+// every call, and records the latency of each call.
+func commandWorker(
+	ctx context.Context,
+	workerID int,
+	client benchClient,
+	keys []string,
+	run *benchmarkRun,
+	stop <-chan struct{},
+	wg *sync.WaitGroup,
+) {
+	defer wg.Done()
+
+	stats := run.workerStats[workerID-1]
+	counters := &run.counters[workerID-1]
+	paceCtx, cancelPace := stopContext(ctx, stop)
+	defer cancelPace()
+
+	rng := workerRand(workerID)
+	picker := workerKeyPicker(rng, workerID, len(run.counters), len(keys))
+	for {
+		select {
+		case <-stop:
+			return
+		default:
+			if run.pace(paceCtx, 1) == 0 {
+				return
+			}
+			key := keys[picker.next()]
+			value := generateValue(rng, workerID, valueSize.get("command"))
+			args := expandCommand(commandTemplate, key, value, rng.Int())
+
+			start := time.Now()
+			err := client.Do(ctx, args...).Err()
+			if err == nil || err == redis.Nil {
+				updateStats(&stats.command, time.Since(start).Seconds()*1000)
+				atomic.AddInt64(&counters.command, 1)
+			} else {
+				recordError(&stats.command)
+			}
+		}
+	}
+}
+
+// printCommandSummary prints the results of a -command run.
+func (r *benchmarkRun) printCommandSummary() {
+	name := commandName()
+	count := r.stats.command.count
+	fmt.Printf("Command: %s\n", strings.Join(commandTemplate, " "))
+	fmt.Printf("%s operations: %d\n", name, count)
+	r.printErrors()
+	fmt.Printf("Average %s ops/sec: %.2f\n", name, float64(count)/r.duration.Seconds())
+	printStats(name, &r.stats.command)
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// exitThresholdBreach is the exit status when -max-p99 or -max-error-rate
+// stays breached for -breach-intervals consecutive intervals.
+const exitThresholdBreach = 3
+
+type thresholdStartKey struct{}
+
+// thresholdMonitor is a go-redis hook that collects the latency and errors
+// of every command over one-second intervals and aborts the run as soon as
+// an SLA threshold has been breached for too many intervals in a row.
+type thresholdMonitor struct {
+	mu       sync.Mutex
+	interval latencyHistogram
+	errors   int
+}
+
+func (m *thresholdMonitor) record(start time.Time, err error) {
+	ms := time.Since(start).Seconds() * 1000
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err != nil && err != redis.Nil {
+		m.errors++
+		return
+	}
+	m.interval.record(ms)
+}
+
+func (m *thresholdMonitor) BeforeProcess(ctx context.Context, cmd redis.Cmder) (context.Context, error) {
+	return context.WithValue(ctx, thresholdStartKey{}, time.Now()), nil
+}
+
+func (m *thresholdMonitor) AfterProcess(ctx context.Context, cmd redis.Cmder) error {
+	if start, ok := ctx.Value(thresholdStartKey{}).(time.Time); ok {
+		m.record(start, cmd.Err())
+	}
+	return nil
+}
+
+func (m *thresholdMonitor) BeforeProcessPipeline(ctx context.Context, cmds []redis.Cmder) (context.Context, error) {
+	return context.WithValue(ctx, thresholdStartKey{}, time.Now()), nil
+}
+
+func (m *thresholdMonitor) AfterProcessPipeline(ctx context.Context, cmds []redis.Cmder) error {
+	if start, ok := ctx.Value(thresholdStartKey{}).(time.Time); ok {
+		for _, cmd := range cmds {
+			m.record(start, cmd.Err())
+		}
+	}
+	return nil
+}
+
+// breach checks the interval collected since the previous call against the
+// thresholds and starts a new one. It returns why the interval breached a
+// threshold, or "" if it did not.
+func (m *thresholdMonitor) breach() string {
+	m.mu.Lock()
+	interval, errors := m.interval, m.errors
+	m.interval.reset()
+	m.errors = 0
+	m.mu.Unlock()
+
+	if maxP99 > 0 {
+		if p99 := interval.percentile(99); p99 > maxP99 {
+			return fmt.Sprintf("p99 %.2f ms exceeded -max-p99 %.2f ms", p99, maxP99)
+		}
+	}
+	if maxErrorRate > 0 {
+		total := int(interval.total) + errors
+		if total > 0 {
+			if rate := float64(errors) / float64(total); rate > maxErrorRate {
+				return fmt.Sprintf("error rate %.2f%% exceeded -max-error-rate %.2f%%", rate*100, maxErrorRate*100)
+			}
+		}
+	}
+	return ""
+}
+
+// watch checks the thresholds every second and exits the process once one
+// has been breached for breachIntervals consecutive intervals.
+func (m *thresholdMonitor) watch(stop <-chan struct{}) {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	start := time.Now()
+	consecutive := 0
+	for {
+		select {
+		case <-stop:
+			return
+		case now := <-ticker.C:
+			reason := m.breach()
+			if reason == "" {
+				consecutive = 0
+				continue
+			}
+			consecutive++
+			if consecutive >= breachIntervals {
+				fmt.Printf("\033[0m\nAborting after %v: %s for %d consecutive intervals\n",
+					now.Sub(start).Round(time.Second), reason, consecutive)
+				exit(exitThresholdBreach)
+			}
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+// ThroughputReport describes how steady throughput was over a run, from the
+// number of operations completed in each one-second interval.
+type ThroughputReport struct {
+	Intervals int     `json:"intervals"`
+	Min       float64 `json:"min_ops_per_sec"`
+	P5        float64 `json:"p5_ops_per_sec"`
+	P50       float64 `json:"p50_ops_per_sec"`
+	P95       float64 `json:"p95_ops_per_sec"`
+	Max       float64 `json:"max_ops_per_sec"`
+}
+
+// sampleThroughput records the operations completed in each second until
+// stop is closed. A partial final second is dropped because it would skew
+// the low percentiles.
+func (r *benchmarkRun) sampleThroughput(stop <-chan struct{}) {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	prev := r.completed()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			cur := r.completed()
+			r.throughput = append(r.throughput, float64(cur-prev))
+			prev = cur
+		}
+	}
+}
+
+// throughputReport summarizes the samples. It must only be called once the
+// run has stopped, because the sampler appends without a lock.
+func (r *benchmarkRun) throughputReport() ThroughputReport {
+	samples := append([]float64(nil), r.throughput...)
+
+	report := ThroughputReport{Intervals: len(samples)}
+	if len(samples) == 0 {
+		return report
+	}
+	sort.Float64s(samples)
+	report.Min = samples[0]
+	report.P5 = percentile(samples, 5)
+	report.P50 = percentile(samples, 50)
+	report.P95 = percentile(samples, 95)
+	report.Max = samples[len(samples)-1]
+	return report
+}
+
+func printThroughput(t ThroughputReport) {
+	if t.Intervals == 0 {
+		fmt.Println("Throughput per second: N/A (run shorter than one second)")
+		return
+	}
+	fmt.Printf("Throughput per second (ops/sec, %d intervals): Min=%.0f, P5=%.0f, P50=%.0f, P95=%.0f, Max=%.0f\n",
+		t.Intervals, t.Min, t.P5, t.P50, t.P95, t.Max)
+}
+
+// percentile returns the p-th percentile of an ascending sorted slice using
+// the nearest-rank method.
+func percentile(sorted []float64, p float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	if rank > len(sorted) {
+		rank = len(sorted)
+	}
+	return sorted[rank-1]
+}
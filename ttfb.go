@@ -0,0 +1,121 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ttfbStats holds time-to-first-byte latency per command name, recorded by
+// ttfbConn.
+type ttfbStats struct {
+	mu    sync.Mutex
+	stats map[string]*operationStats
+}
+
+func newTTFBStats() *ttfbStats {
+	return &ttfbStats{stats: make(map[string]*operationStats)}
+}
+
+func (t *ttfbStats) record(command string, ms float64) {
+	t.mu.Lock()
+	s, ok := t.stats[command]
+	if !ok {
+		s = &operationStats{minTime: math.MaxFloat64}
+		t.stats[command] = s
+	}
+	t.mu.Unlock()
+	updateStats(s, ms)
+}
+
+func (t *ttfbStats) get(command string) *operationStats {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.stats[command]
+}
+
+// ttfbConn times the first Read after each Write, which is when the first
+// byte of the reply arrives. The command is taken from the RESP request;
+// for a pipeline it is the first command in it.
+type ttfbConn struct {
+	net.Conn
+	stats   *ttfbStats
+	sent    time.Time
+	command string
+	waiting bool
+}
+
+func (c *ttfbConn) Write(b []byte) (int, error) {
+	c.command = respCommand(b)
+	c.sent = time.Now()
+	c.waiting = true
+	return c.Conn.Write(b)
+}
+
+func (c *ttfbConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	if c.waiting && n > 0 {
+		c.waiting = false
+		c.stats.record(c.command, time.Since(c.sent).Seconds()*1000)
+	}
+	return n, err
+}
+
+// respCommand extracts the command name from a RESP array request such as
+// "*2\r\n$3\r\nget\r\n$3\r\nkey\r\n".
+func respCommand(b []byte) string {
+	// Skip the array header and the bulk string length of the name
+	for i := 0; i < 2; i++ {
+		j := bytes.Index(b, []byte("\r\n"))
+		if j < 0 {
+			return ""
+		}
+		b = b[j+2:]
+	}
+	if j := bytes.Index(b, []byte("\r\n")); j >= 0 {
+		return strings.ToUpper(string(b[:j]))
+	}
+	return ""
+}
+
+// ttfbDialer wraps base, or a plain net.Dialer when base is nil, so every
+// connection is a ttfbConn.
+func ttfbDialer(stats *ttfbStats, base func(ctx context.Context, network, addr string) (net.Conn, error)) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	if base == nil {
+		var d net.Dialer
+		base = d.DialContext
+	}
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		conn, err := base(ctx, network, addr)
+		if err != nil {
+			return nil, err
+		}
+		return &ttfbConn{Conn: conn, stats: stats}, nil
+	}
+}
+
+// printTTFB splits GET latency into time to first byte, which covers the
+// network round trip and server processing, and the transfer of the rest of
+// the reply.
+func printTTFB(stats *ttfbStats, get *operationStats) {
+	ttfb := stats.get("GET")
+	if ttfb == nil || ttfb.count == 0 || get.count == 0 {
+		fmt.Println("GET time to first byte: N/A")
+		return
+	}
+	printStats("GET time to first byte", ttfb)
+	ttfbAvg := ttfb.totalTime / float64(ttfb.count)
+	fullAvg := get.totalTime / float64(get.count)
+	transfer := math.Max(fullAvg-ttfbAvg, 0)
+	share := 0.0
+	if fullAvg > 0 {
+		share = transfer / fullAvg * 100
+	}
+	fmt.Printf("GET latency split (avg ms): first byte=%.3f, full response=%.3f, transfer=%.3f (%.1f%% of full)\n",
+		ttfbAvg, fullAvg, transfer, share)
+}
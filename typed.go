@@ -0,0 +1,44 @@
+package main
+
+import "fmt"
+
+// The INCR, HSET and LPUSH operations work on keys of their own, derived
+// from the benchmark key, so they never hit a string written by SET with
+// the wrong type.
+const (
+	counterKeySuffix = ":counter"
+	hashKeySuffix    = ":hash"
+	listKeySuffix    = ":list"
+)
+
+// hashFields are the fields HSET writes, so hashes stay a bounded size.
+var hashFields = func() []string {
+	fields := make([]string, 16)
+	for i := range fields {
+		fields[i] = fmt.Sprintf("f%d", i)
+	}
+	return fields
+}()
+
+// typedOp is an operation selected by its own ratio flag, besides SET, GET
+// and DEL.
+type typedOp struct {
+	name  string
+	ratio float64
+	stats *operationStats
+}
+
+// typedOps returns the INCR, HSET and LPUSH operations enabled for the run.
+func (r *benchmarkRun) typedOps() []typedOp {
+	var ops []typedOp
+	for _, op := range []typedOp{
+		{"INCR", r.ratios.incr, &r.stats.incr},
+		{"HSET", r.ratios.hset, &r.stats.hset},
+		{"LPUSH", r.ratios.lpush, &r.stats.lpush},
+	} {
+		if op.ratio > 0 {
+			ops = append(ops, op)
+		}
+	}
+	return ops
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// defaultValueSize is the number of bytes written per value unless
+// -value-size says otherwise.
+const defaultValueSize = 100
+
+// valueSizeOps are the operations that write a generated value. SET covers
+// the hybrid pipelines and latency calibration, lpush the -lpush operations
+// and the -queue-consumers producers, pipeline the {value} of
+// -command-pipeline-file templates, command the {value} of -command and
+// publish the -mode pubsub messages, after their timestamp.
+var valueSizeOps = []string{"set", "hset", "lpush", "pipeline", "command", "publish"}
+
+// valueSizes is the -value-size flag: a default size in bytes, optionally
+// followed by per-operation overrides such as "100,set=256,lpush=16".
+type valueSizes struct {
+	def int
+	ops map[string]int
+}
+
+func (v *valueSizes) String() string {
+	parts := []string{strconv.Itoa(v.def)}
+	names := make([]string, 0, len(v.ops))
+	for name := range v.ops {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		parts = append(parts, fmt.Sprintf("%s=%d", name, v.ops[name]))
+	}
+	return strings.Join(parts, ",")
+}
+
+func (v *valueSizes) Set(s string) error {
+	ops := map[string]int{}
+	def := v.def
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		name, size, ok := strings.Cut(part, "=")
+		if !ok {
+			size = part
+		}
+		n, err := strconv.Atoi(size)
+		if err != nil || n < 1 {
+			return fmt.Errorf("invalid size %q: expected a positive number of bytes", size)
+		}
+		if !ok {
+			def = n
+			continue
+		}
+		if !knownValueSizeOp(name) {
+			return fmt.Errorf("unknown operation %q: expected one of %s", name, strings.Join(valueSizeOps, ", "))
+		}
+		ops[name] = n
+	}
+	v.def, v.ops = def, ops
+	return nil
+}
+
+func knownValueSizeOp(name string) bool {
+	for _, op := range valueSizeOps {
+		if op == name {
+			return true
+		}
+	}
+	return false
+}
+
+// get returns the value size for op.
+func (v *valueSizes) get(op string) int {
+	if n, ok := v.ops[op]; ok {
+		return n
+	}
+	return v.def
+}
+
+// valueSizes returns the size of the values written by each operation of
+// the run, keyed by operation name, or nil when it only writes integers.
+func (r *benchmarkRun) valueSizes() map[string]int {
+	switch {
+	case benchMode == modePubSub:
+		return map[string]int{"PUBLISH": valueSize.get("publish")}
+	case benchMode == modeScan:
+		return nil
+	case queueConsumers > 0:
+		return map[string]int{"LPUSH": valueSize.get("lpush")}
+	case pipelineCommands != nil:
+		return map[string]int{"PIPELINE": valueSize.get("pipeline")}
+	case commandTemplate != nil:
+		return map[string]int{commandName(): valueSize.get("command")}
+	}
+	sizes := map[string]int{}
+	if !r.intValues {
+		sizes["SET"] = r.valueSize
+	}
+	if r.ratios.hset > 0 {
+		sizes["HSET"] = valueSize.get("hset")
+	}
+	if r.ratios.lpush > 0 {
+		sizes["LPUSH"] = valueSize.get("lpush")
+	}
+	if len(sizes) == 0 {
+		return nil
+	}
+	return sizes
+}
+
+// setValueSize returns the size of the next SET value: the run's value size,
+// or with -value-size-max a uniformly random size up to that maximum.
+func (r *benchmarkRun) setValueSize(rng *rand.Rand) int {
+	return randomValueSize(rng, r.valueSize, r.valueSizeMax)
+}
+
+// randomValueSize returns size, or a uniformly random size in
+// [size, maxSize] when maxSize is larger.
+func randomValueSize(rng *rand.Rand, size, maxSize int) int {
+	if maxSize <= size {
+		return size
+	}
+	return size + rng.Intn(maxSize-size+1)
+}
+
+// printValueSizes prints the value size of each operation; setMax, when
+// non-zero, is the upper bound of the random SET value sizes.
+func printValueSizes(sizes map[string]int, setMax int) {
+	names := make([]string, 0, len(sizes))
+	for name := range sizes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if name == "SET" && setMax > 0 {
+			fmt.Printf("SET value size: %d-%d bytes (uniformly random per SET)\n", sizes[name], setMax)
+			continue
+		}
+		fmt.Printf("%s value size: %d bytes\n", name, sizes[name])
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"hash/crc32"
+	"sync"
+)
+
+// checksumStore remembers CRC32 checksums of values written to each key so
+// reads can be verified without keeping the values themselves in memory.
+//
+// Several clients may write the same key while a GET is in flight, so each
+// key keeps the checksums of its most recent writes and a read is accepted
+// if it matches any of them. DEL does not clear the history: a GET after a
+// DEL returns nil and is not checked, while clearing it would race with
+// concurrent writes.
+type checksumStore struct {
+	mu         sync.Mutex
+	sums       map[string][]uint32
+	history    int
+	checked    int
+	mismatches int
+}
+
+// newChecksumStore keeps enough history per key to cover one in-flight
+// write from every client.
+func newChecksumStore(clients int) *checksumStore {
+	history := clients + 1
+	if history > 32 {
+		history = 32
+	}
+	return &checksumStore{sums: make(map[string][]uint32), history: history}
+}
+
+// writing records the checksum of a value before it is sent with SET, so a
+// concurrent GET that already observes the new value is not a mismatch.
+func (c *checksumStore) writing(key string, value []byte) {
+	sum := crc32.ChecksumIEEE(value)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	sums := append(c.sums[key], sum)
+	if len(sums) > c.history {
+		sums = sums[len(sums)-c.history:]
+	}
+	c.sums[key] = sums
+}
+
+// read compares a value returned by GET against the recent writes to key.
+// Keys that were not written during this run are not checked.
+func (c *checksumStore) read(key, value string) {
+	sum := crc32.ChecksumIEEE([]byte(value))
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	sums, ok := c.sums[key]
+	if !ok {
+		return
+	}
+	c.checked++
+	for _, s := range sums {
+		if s == sum {
+			return
+		}
+	}
+	c.mismatches++
+}
+
+// counts returns the number of verified reads and mismatches so far.
+func (c *checksumStore) counts() (checked, mismatches int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.checked, c.mismatches
+}
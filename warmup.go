@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+)
+
+// warmupStableIntervals is how many consecutive intervals must stay within
+// -warmup-tolerance of each other before the warmup counts as stable.
+const warmupStableIntervals = 3
+
+// fixedWarmup runs the workload for d against a throwaway run, so pool
+// ramp-up and cold caches do not reach the counters or latency statistics
+// of the measured run.
+func fixedWarmup(ctx context.Context, ratios opRatios, clients opClients, keys []string, d time.Duration) {
+	run := newBenchmarkRun(ratios, numClients)
+	stop := run.start(ctx, clients, keys, false)
+	time.Sleep(d)
+	stop()
+}
+
+// adaptiveWarmup runs the workload without recording results until the
+// throughput of successive -warmup-interval windows stays within
+// -warmup-tolerance, or until -warmup-max has passed. It returns how long the
+// warmup took and whether throughput stabilized.
+func adaptiveWarmup(ctx context.Context, ratios opRatios, clients opClients, keys []string) (time.Duration, bool) {
+	run := newBenchmarkRun(ratios, numClients)
+	start := time.Now()
+	stop := run.start(ctx, clients, keys, false)
+	defer stop()
+
+	ticker := time.NewTicker(warmupInterval)
+	defer ticker.Stop()
+
+	last, prevRate, stableFor := 0, 0.0, 0
+	for range ticker.C {
+		completed := run.completed()
+		rate := float64(completed-last) / warmupInterval.Seconds()
+		last = completed
+
+		if prevRate > 0 && math.Abs(rate-prevRate)/prevRate <= warmupTolerance {
+			stableFor++
+		} else {
+			stableFor = 0
+		}
+		prevRate = rate
+
+		if stableFor >= warmupStableIntervals {
+			return time.Since(start), true
+		}
+		if time.Since(start) >= warmupMax {
+			return time.Since(start), false
+		}
+	}
+	return time.Since(start), false
+}
+
+// printWarmupResult reports the outcome of adaptiveWarmup.
+func printWarmupResult(elapsed time.Duration, stable bool) {
+	if stable {
+		fmt.Printf("Warmup: throughput stabilized after %v\n", elapsed.Round(time.Millisecond))
+		return
+	}
+	fmt.Printf("Warmup: throughput did not stabilize within %v, starting measurement anyway\n", warmupMax)
+}
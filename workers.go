@@ -0,0 +1,60 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// workerOutlierThreshold is how far, relative to the mean, a worker's
+// throughput may stray before the summary flags it.
+const workerOutlierThreshold = 0.25
+
+// WorkerReport holds the operations completed by one client worker.
+type WorkerReport struct {
+	ID         int     `json:"id"`
+	Operations int     `json:"operations"`
+	OpsPerSec  float64 `json:"ops_per_sec"`
+	Outlier    bool    `json:"outlier"`
+}
+
+// workerReports returns every worker's totals and flags those whose
+// throughput is more than workerOutlierThreshold away from the mean, which
+// points at uneven scheduling, connection pinning or per-connection
+// throttling.
+func (r *benchmarkRun) workerReports(duration time.Duration) []WorkerReport {
+	workers := make([]WorkerReport, len(r.counters))
+	total := 0
+	for i := range r.counters {
+		ops := r.counters[i].operations()
+		workers[i] = WorkerReport{ID: i + 1, Operations: ops, OpsPerSec: float64(ops) / duration.Seconds()}
+		total += ops
+	}
+
+	if len(workers) == 0 || total == 0 {
+		return workers
+	}
+	mean := float64(total) / float64(len(workers))
+	for i := range workers {
+		deviation := math.Abs(float64(workers[i].Operations)-mean) / mean
+		workers[i].Outlier = deviation > workerOutlierThreshold
+	}
+	return workers
+}
+
+func printWorkerTable(workers []WorkerReport) {
+	fmt.Println("Per-worker throughput:")
+	fmt.Printf("  %-8s %12s %12s\n", "Worker", "Operations", "Ops/sec")
+	outliers := 0
+	for _, w := range workers {
+		mark := ""
+		if w.Outlier {
+			mark = "  <- outlier"
+			outliers++
+		}
+		fmt.Printf("  %-8d %12d %12.2f%s\n", w.ID, w.Operations, w.OpsPerSec, mark)
+	}
+	if outliers > 0 {
+		fmt.Printf("%d worker(s) more than %.0f%% away from the mean throughput\n", outliers, workerOutlierThreshold*100)
+	}
+}